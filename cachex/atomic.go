@@ -0,0 +1,29 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// NoExpiration TTL 返回该值表示 key 永不过期
+const NoExpiration time.Duration = -1
+
+// ErrNotInteger 对非整数值执行计数操作
+var ErrNotInteger = errors.New("cachex: value is not an integer")
+
+// AtomicCacher 定义原子计数及过期时间管理接口,内置的 cache 驱动均已实现
+type AtomicCacher interface {
+	// Incr 将值加 1,key 不存在时从 0 开始计数并设置过期时间
+	Incr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error)
+	// IncrBy 将值加 value,key 不存在时从 0 开始计数并设置过期时间,已存在的 key 保留原过期时间
+	IncrBy(ctx context.Context, ns, key string, value int64, expiration ...time.Duration) (int64, error)
+	// Decr 将值减 1,key 不存在时从 0 开始计数并设置过期时间
+	Decr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error)
+	// SetNX key 不存在时设置,返回是否设置成功
+	SetNX(ctx context.Context, ns, key, value string, expiration ...time.Duration) (bool, error)
+	// TTL 获取剩余有效期,永不过期时返回 NoExpiration,key 不存在时返回 false
+	TTL(ctx context.Context, ns, key string) (time.Duration, bool, error)
+	// Expire 设置有效期,expiration <= 0 时移除过期时间,key 不存在时返回 false
+	Expire(ctx context.Context, ns, key string, expiration time.Duration) (bool, error)
+}
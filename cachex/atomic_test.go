@@ -0,0 +1,148 @@
+package cachex
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testAtomicCacher(t *testing.T, cache Cacher) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	ac, ok := cache.(AtomicCacher)
+	assert.True(ok)
+
+	// concurrent increments are not lost
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ac.Incr(ctx, "atomic", "counter", time.Minute)
+			assert.Nil(err)
+		}()
+	}
+	wg.Wait()
+
+	n, err := ac.IncrBy(ctx, "atomic", "counter", 10)
+	assert.Nil(err)
+	assert.Equal(int64(30), n)
+	n, err = ac.Decr(ctx, "atomic", "counter")
+	assert.Nil(err)
+	assert.Equal(int64(29), n)
+
+	// ttl is set on create and kept afterwards
+	ttl, exists, err := ac.TTL(ctx, "atomic", "counter")
+	assert.Nil(err)
+	assert.True(exists)
+	assert.True(ttl > 0 && ttl <= time.Minute)
+
+	ok, err = ac.Expire(ctx, "atomic", "counter", 0)
+	assert.Nil(err)
+	assert.True(ok)
+	ttl, exists, err = ac.TTL(ctx, "atomic", "counter")
+	assert.Nil(err)
+	assert.True(exists)
+	assert.Equal(NoExpiration, ttl)
+
+	_, exists, err = ac.TTL(ctx, "atomic", "none")
+	assert.Nil(err)
+	assert.False(exists)
+	ok, err = ac.Expire(ctx, "atomic", "none", time.Minute)
+	assert.Nil(err)
+	assert.False(ok)
+
+	ok, err = ac.SetNX(ctx, "atomic", "lock", "1", time.Minute)
+	assert.Nil(err)
+	assert.True(ok)
+	ok, err = ac.SetNX(ctx, "atomic", "lock", "2", time.Minute)
+	assert.Nil(err)
+	assert.False(ok)
+
+	_, err = ac.Incr(ctx, "atomic", "lock")
+	assert.Nil(err)
+	assert.Nil(cache.Set(ctx, "atomic", "str", "foo"))
+	_, err = ac.Incr(ctx, "atomic", "str")
+	assert.ErrorIs(err, ErrNotInteger)
+
+	assert.Nil(MDelete(ctx, cache, "atomic", "counter", "lock", "str"))
+	assert.Nil(cache.Close(ctx))
+}
+
+func TestAtomicCacher(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testAtomicCacher(t, NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute}))
+	})
+	t.Run("badger", func(t *testing.T) {
+		testAtomicCacher(t, newTestBadgerCache(t, BadgerConfig{Path: t.TempDir()}))
+	})
+	t.Run("redis", func(t *testing.T) {
+		testAtomicCacher(t, NewRedisCacheWithClient(newTestRedisClient(t)))
+	})
+	t.Run("twolevel", func(t *testing.T) {
+		testAtomicCacher(t, NewTwoLevelCacheWithClient(newTestRedisClient(t), TwoLevelConfig{}))
+	})
+}
+
+func testGetAndDelete(t *testing.T, cache Cacher) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	for round := 0; round < 5; round++ {
+		assert.Nil(cache.Set(ctx, "otp", "code", "123456"))
+
+		var (
+			wg   sync.WaitGroup
+			mu   sync.Mutex
+			hits int
+		)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				value, ok, err := cache.GetAndDelete(ctx, "otp", "code")
+				assert.Nil(err)
+				if ok {
+					assert.Equal("123456", value)
+					mu.Lock()
+					hits++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(1, hits)
+	}
+
+	_, ok, err := cache.GetAndDelete(ctx, "otp", "none")
+	assert.Nil(err)
+	assert.False(ok)
+	assert.Nil(cache.Close(ctx))
+}
+
+func TestGetAndDelete(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testGetAndDelete(t, NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute}))
+	})
+	t.Run("bounded", func(t *testing.T) {
+		testGetAndDelete(t, NewBoundedMemoryCache(BoundedMemoryConfig{}))
+	})
+	t.Run("badger", func(t *testing.T) {
+		testGetAndDelete(t, newTestBadgerCache(t, BadgerConfig{Path: t.TempDir()}))
+	})
+	t.Run("redis", func(t *testing.T) {
+		testGetAndDelete(t, NewRedisCacheWithClient(newTestRedisClient(t)))
+	})
+	t.Run("redis lua", func(t *testing.T) {
+		cache := NewRedisCacheWithClient(newTestRedisClient(t))
+		cache.(*redisCache).noGetDel.Store(true)
+		testGetAndDelete(t, cache)
+	})
+	t.Run("twolevel", func(t *testing.T) {
+		testGetAndDelete(t, NewTwoLevelCacheWithClient(newTestRedisClient(t), TwoLevelConfig{}))
+	})
+}
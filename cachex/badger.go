@@ -4,19 +4,33 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unsafe"
 
 	"github.com/dgraph-io/badger/v3"
+
+	"github.com/gopkg-dev/karma/log"
+)
+
+const (
+	defaultBadgerGCInterval     = 5 * time.Minute
+	defaultBadgerGCDiscardRatio = 0.5
+	badgerMaxConflictRetries    = 100
 )
 
 type BadgerConfig struct {
-	Path string
+	Path           string
+	InMemory       bool          // 仅在内存中保存数据,忽略 Path
+	EncryptionKey  string        // 数据加密密钥,长度为 16/24/32 字节(AES-128/192/256),为空时不加密
+	GCInterval     time.Duration // value log GC 间隔,默认 5 分钟,小于 0 表示不执行
+	GCDiscardRatio float64       // value log 文件可回收比例超过该值时重写,默认 0.5
 }
 
 // NewBadgerCache Create badger-based cache
-func NewBadgerCache(cfg BadgerConfig, opts ...Option) Cacher {
+func NewBadgerCache(cfg BadgerConfig, opts ...Option) (Cacher, error) {
 	defaultOpts := &options{
 		Delimiter: defaultDelimiter,
 	}
@@ -26,21 +40,68 @@ func NewBadgerCache(cfg BadgerConfig, opts ...Option) Cacher {
 	}
 
 	badgerOpts := badger.DefaultOptions(cfg.Path)
+	if cfg.InMemory {
+		badgerOpts = badger.DefaultOptions("").WithInMemory(true)
+	}
 	badgerOpts = badgerOpts.WithLoggingLevel(badger.ERROR)
+	if cfg.EncryptionKey != "" {
+		// 开启加密时必须设置索引缓存
+		badgerOpts = badgerOpts.WithEncryptionKey([]byte(cfg.EncryptionKey)).WithIndexCacheSize(100 << 20)
+	}
 	db, err := badger.Open(badgerOpts)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	return &badgerCache{
+	if cfg.GCInterval == 0 {
+		cfg.GCInterval = defaultBadgerGCInterval
+	}
+	if cfg.GCDiscardRatio <= 0 || cfg.GCDiscardRatio >= 1 {
+		cfg.GCDiscardRatio = defaultBadgerGCDiscardRatio
+	}
+
+	a := &badgerCache{
 		opts: defaultOpts,
 		db:   db,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if cfg.GCInterval > 0 && !cfg.InMemory {
+		go a.runGC(cfg.GCInterval, cfg.GCDiscardRatio)
+	} else {
+		close(a.done)
 	}
+	return a, nil
 }
 
 type badgerCache struct {
 	opts *options
 	db   *badger.DB
+	once sync.Once
+	stop chan struct{}
+	done chan struct{}
+}
+
+// runGC 定期回收 value log,直到没有可回收的文件
+func (a *badgerCache) runGC(interval time.Duration, discardRatio float64) {
+	defer close(a.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			for {
+				if err := a.db.RunValueLogGC(discardRatio); err != nil {
+					if !errors.Is(err, badger.ErrNoRewrite) && !errors.Is(err, badger.ErrRejected) {
+						log.Warnf("cachex: badger value log gc: %v", err)
+					}
+					break
+				}
+			}
+		}
+	}
 }
 
 func (a *badgerCache) getKey(ns, key string) string {
@@ -63,7 +124,7 @@ func (a *badgerCache) bytesToStr(b []byte) string {
 func (a *badgerCache) Set(ctx context.Context, ns, key, value string, expiration ...time.Duration) error {
 	return a.db.Update(func(txn *badger.Txn) error {
 		entry := badger.NewEntry(a.strToBytes(a.getKey(ns, key)), a.strToBytes(value))
-		if len(expiration) > 0 {
+		if len(expiration) > 0 && expiration[0] > 0 {
 			entry = entry.WithTTL(expiration[0])
 		}
 		return txn.SetEntry(entry)
@@ -109,40 +170,237 @@ func (a *badgerCache) Exists(ctx context.Context, ns, key string) (bool, error)
 }
 
 func (a *badgerCache) Delete(ctx context.Context, ns, key string) error {
-	b, err := a.Exists(ctx, ns, key)
-	if err != nil {
-		return err
-	} else if !b {
-		return nil
-	}
-
-	return a.db.Update(func(txn *badger.Txn) error {
+	return a.update(ctx, func(txn *badger.Txn) error {
 		return txn.Delete(a.strToBytes(a.getKey(ns, key)))
 	})
 }
 
 func (a *badgerCache) GetAndDelete(ctx context.Context, ns, key string) (string, bool, error) {
-	value, ok, err := a.Get(ctx, ns, key)
-	if err != nil {
+	var (
+		value string
+		ok    bool
+	)
+	err := a.update(ctx, func(txn *badger.Txn) error {
+		k := a.strToBytes(a.getKey(ns, key))
+		item, err := txn.Get(k)
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				ok = false
+				return nil
+			}
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		value, ok = a.bytesToStr(val), true
+		return txn.Delete(k)
+	})
+	if err != nil || !ok {
 		return "", false, err
-	} else if !ok {
-		return "", false, nil
 	}
+	return value, true, nil
+}
 
-	err = a.db.Update(func(txn *badger.Txn) error {
-		return txn.Delete(a.strToBytes(a.getKey(ns, key)))
+func (a *badgerCache) MGet(ctx context.Context, ns string, keys ...string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	err := a.db.View(func(txn *badger.Txn) error {
+		for _, key := range keys {
+			item, err := txn.Get(a.strToBytes(a.getKey(ns, key)))
+			if err != nil {
+				if errors.Is(err, badger.ErrKeyNotFound) {
+					continue
+				}
+				return err
+			}
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			values[key] = a.bytesToStr(val)
+		}
+		return nil
 	})
 	if err != nil {
-		return "", false, err
+		return nil, err
 	}
+	return values, nil
+}
 
-	return value, true, nil
+// MSet 通过 WriteBatch 分批提交,避免大批量写入超出单个事务的限制(ErrTxnTooBig),批量写入不保证原子性
+func (a *badgerCache) MSet(ctx context.Context, ns string, values map[string]string, expiration ...time.Duration) error {
+	wb := a.db.NewWriteBatch()
+	defer wb.Cancel()
+	for key, value := range values {
+		entry := badger.NewEntry(a.strToBytes(a.getKey(ns, key)), a.strToBytes(value))
+		if len(expiration) > 0 && expiration[0] > 0 {
+			entry = entry.WithTTL(expiration[0])
+		}
+		if err := wb.SetEntry(entry); err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+// MDelete 与 MSet 相同,通过 WriteBatch 分批提交
+func (a *badgerCache) MDelete(ctx context.Context, ns string, keys ...string) error {
+	wb := a.db.NewWriteBatch()
+	defer wb.Cancel()
+	for _, key := range keys {
+		if err := wb.Delete(a.strToBytes(a.getKey(ns, key))); err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+// update 执行读写事务,事务冲突时重试,最多重试 badgerMaxConflictRetries 次,ctx 结束时停止重试
+func (a *badgerCache) update(ctx context.Context, fn func(txn *badger.Txn) error) error {
+	var err error
+	for i := 0; i <= badgerMaxConflictRetries; i++ {
+		if i > 0 {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+		}
+		err = a.db.Update(fn)
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+func (a *badgerCache) Incr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	return a.IncrBy(ctx, ns, key, 1, expiration...)
+}
+
+func (a *badgerCache) Decr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	return a.IncrBy(ctx, ns, key, -1, expiration...)
+}
+
+func (a *badgerCache) IncrBy(ctx context.Context, ns, key string, value int64, expiration ...time.Duration) (int64, error) {
+	var result int64
+	err := a.update(ctx, func(txn *badger.Txn) error {
+		k := a.strToBytes(a.getKey(ns, key))
+		n := value
+		entry := badger.NewEntry(k, nil)
+
+		item, err := txn.Get(k)
+		if err == nil {
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			current, err := strconv.ParseInt(a.bytesToStr(val), 10, 64)
+			if err != nil {
+				return ErrNotInteger
+			}
+			n += current
+			entry.ExpiresAt = item.ExpiresAt()
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		} else if len(expiration) > 0 && expiration[0] > 0 {
+			entry = entry.WithTTL(expiration[0])
+		}
+
+		entry.Value = []byte(strconv.FormatInt(n, 10))
+		if err := txn.SetEntry(entry); err != nil {
+			return err
+		}
+		result = n
+		return nil
+	})
+	return result, err
+}
+
+func (a *badgerCache) SetNX(ctx context.Context, ns, key, value string, expiration ...time.Duration) (bool, error) {
+	ok := false
+	err := a.update(ctx, func(txn *badger.Txn) error {
+		ok = false
+		k := a.strToBytes(a.getKey(ns, key))
+		if _, err := txn.Get(k); err == nil {
+			return nil
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		entry := badger.NewEntry(k, a.strToBytes(value))
+		if len(expiration) > 0 && expiration[0] > 0 {
+			entry = entry.WithTTL(expiration[0])
+		}
+		if err := txn.SetEntry(entry); err != nil {
+			return err
+		}
+		ok = true
+		return nil
+	})
+	return ok, err
+}
+
+func (a *badgerCache) TTL(ctx context.Context, ns, key string) (time.Duration, bool, error) {
+	var ttl time.Duration
+	exists := false
+	err := a.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(a.strToBytes(a.getKey(ns, key)))
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return err
+		}
+		exists = true
+		if expiresAt := item.ExpiresAt(); expiresAt == 0 {
+			ttl = NoExpiration
+		} else {
+			ttl = time.Until(time.Unix(int64(expiresAt), 0))
+		}
+		return nil
+	})
+	return ttl, exists, err
+}
+
+func (a *badgerCache) Expire(ctx context.Context, ns, key string, expiration time.Duration) (bool, error) {
+	exists := false
+	err := a.update(ctx, func(txn *badger.Txn) error {
+		exists = false
+		k := a.strToBytes(a.getKey(ns, key))
+		item, err := txn.Get(k)
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		entry := badger.NewEntry(k, val)
+		if expiration > 0 {
+			entry = entry.WithTTL(expiration)
+		}
+		if err := txn.SetEntry(entry); err != nil {
+			return err
+		}
+		exists = true
+		return nil
+	})
+	return exists, err
 }
 
 func (a *badgerCache) Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error {
+	return a.iterate(ctx, ns, "", fn)
+}
+
+// iterate 遍历命名空间下以 prefix 开头的 key
+func (a *badgerCache) iterate(ctx context.Context, ns, prefix string, fn func(ctx context.Context, key, value string) bool) error {
 	return a.db.View(func(txn *badger.Txn) error {
 		iterOpts := badger.DefaultIteratorOptions
-		iterOpts.Prefix = a.strToBytes(a.getKey(ns, ""))
+		iterOpts.Prefix = a.strToBytes(a.getKey(ns, prefix))
 		it := txn.NewIterator(iterOpts)
 		defer it.Close()
 
@@ -153,7 +411,7 @@ func (a *badgerCache) Iterator(ctx context.Context, ns string, fn func(ctx conte
 			if err != nil {
 				return err
 			}
-			key := a.bytesToStr(item.Key())
+			key := string(item.Key()) // item.Key() 在迭代中会被复用
 			if !fn(ctx, strings.TrimPrefix(key, a.getKey(ns, "")), a.bytesToStr(val)) {
 				break
 			}
@@ -163,6 +421,50 @@ func (a *badgerCache) Iterator(ctx context.Context, ns string, fn func(ctx conte
 	})
 }
 
+func (a *badgerCache) ClearNamespace(ctx context.Context, ns string, progress ...ProgressFunc) (int64, error) {
+	n, err := a.Count(ctx, ns)
+	if err != nil {
+		return 0, err
+	}
+	if err := a.db.DropPrefix(a.strToBytes(a.getKey(ns, ""))); err != nil {
+		return 0, err
+	}
+	reportProgress(progress, n)
+	return n, nil
+}
+
+func (a *badgerCache) Count(ctx context.Context, ns string) (int64, error) {
+	var n int64
+	err := a.db.View(func(txn *badger.Txn) error {
+		iterOpts := badger.DefaultIteratorOptions
+		iterOpts.PrefetchValues = false
+		iterOpts.Prefix = a.strToBytes(a.getKey(ns, ""))
+		it := txn.NewIterator(iterOpts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+func (a *badgerCache) IteratorMatch(ctx context.Context, ns, pattern string, fn func(ctx context.Context, key, value string) bool) error {
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return err
+	}
+	return a.iterate(ctx, ns, literalPrefix(pattern), func(ctx context.Context, key, value string) bool {
+		if !re.MatchString(key) {
+			return true
+		}
+		return fn(ctx, key, value)
+	})
+}
+
 func (a *badgerCache) Close(ctx context.Context) error {
+	a.once.Do(func() { close(a.stop) })
+	<-a.done
 	return a.db.Close()
 }
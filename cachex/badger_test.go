@@ -3,20 +3,28 @@ package cachex
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/dgraph-io/badger/v3"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestBadgerCache(t *testing.T) {
 	assert := assert.New(t)
 
-	cache := NewBadgerCache(BadgerConfig{
+	cache, err := NewBadgerCache(BadgerConfig{
 		Path: "./tmp/badger",
 	})
+	assert.Nil(err)
 
 	ctx := context.Background()
-	err := cache.Set(ctx, "tt", "foo", "bar")
+	err = cache.Set(ctx, "tt", "foo", "bar")
 	assert.Nil(err)
 
 	val, exists, err := cache.Get(ctx, "tt", "foo")
@@ -54,3 +62,147 @@ func TestBadgerCache(t *testing.T) {
 	err = cache.Close(ctx)
 	assert.Nil(err)
 }
+
+func newTestBadgerCache(t *testing.T, cfg BadgerConfig) Cacher {
+	cache, err := NewBadgerCache(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cache
+}
+
+func TestBadgerCacheOptions(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	// open failure is returned instead of panicking
+	file := filepath.Join(t.TempDir(), "file")
+	assert.Nil(os.WriteFile(file, []byte("x"), 0o600))
+	_, err := NewBadgerCache(BadgerConfig{Path: file})
+	assert.NotNil(err)
+
+	cache := newTestBadgerCache(t, BadgerConfig{InMemory: true})
+	assert.Nil(cache.Set(ctx, "mem", "foo", "bar"))
+	val, exists, err := cache.Get(ctx, "mem", "foo")
+	assert.Nil(err)
+	assert.True(exists)
+	assert.Equal("bar", val)
+	assert.Nil(cache.Close(ctx))
+
+	dir := t.TempDir()
+	cache = newTestBadgerCache(t, BadgerConfig{Path: dir, EncryptionKey: "0123456789abcdef", GCInterval: 10 * time.Millisecond})
+	assert.Nil(cache.Set(ctx, "enc", "foo", "bar"))
+	time.Sleep(30 * time.Millisecond)
+	assert.Nil(cache.Close(ctx))
+
+	_, err = NewBadgerCache(BadgerConfig{Path: dir, EncryptionKey: "fedcba9876543210"})
+	assert.NotNil(err)
+
+	cache = newTestBadgerCache(t, BadgerConfig{Path: dir, EncryptionKey: "0123456789abcdef", GCInterval: -1})
+	val, exists, err = cache.Get(ctx, "enc", "foo")
+	assert.Nil(err)
+	assert.True(exists)
+	assert.Equal("bar", val)
+	assert.Nil(cache.Close(ctx))
+}
+
+func TestBadgerCacheGetAndDelete(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	cache := newTestBadgerCache(t, BadgerConfig{InMemory: true})
+	assert.Nil(cache.Set(ctx, "gd", "foo", "bar"))
+
+	var (
+		wg   sync.WaitGroup
+		hits int32
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, ok, err := cache.GetAndDelete(ctx, "gd", "foo")
+			assert.Nil(err)
+			if ok {
+				atomic.AddInt32(&hits, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(int32(1), hits)
+
+	assert.Nil(cache.Delete(ctx, "gd", "none"))
+	assert.Nil(cache.Close(ctx))
+}
+
+func TestBadgerCacheZeroTTL(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	// 0 means no expiry, as in the other backends
+	cache := newTestBadgerCache(t, BadgerConfig{InMemory: true})
+	assert.Nil(cache.Set(ctx, "ttl", "one", "1", 0))
+	assert.Nil(MSet(ctx, cache, "ttl", map[string]string{"a": "1", "b": "2"}, 0))
+
+	values, err := MGet(ctx, cache, "ttl", "one", "a", "b")
+	assert.Nil(err)
+	assert.Equal(map[string]string{"one": "1", "a": "1", "b": "2"}, values)
+
+	ttl, ok, err := cache.(AtomicCacher).TTL(ctx, "ttl", "a")
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal(NoExpiration, ttl)
+	assert.Nil(cache.Close(ctx))
+}
+
+func TestBadgerCacheConflictRetry(t *testing.T) {
+	assert := assert.New(t)
+
+	cache := newTestBadgerCache(t, BadgerConfig{InMemory: true})
+	defer cache.Close(context.Background())
+	a := cache.(*badgerCache)
+
+	var calls int
+	conflict := func(txn *badger.Txn) error {
+		calls++
+		return badger.ErrConflict
+	}
+
+	// retries are capped
+	assert.ErrorIs(a.update(context.Background(), conflict), badger.ErrConflict)
+	assert.Equal(badgerMaxConflictRetries+1, calls)
+
+	// a cancelled ctx stops retrying
+	calls = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(a.update(ctx, conflict), context.Canceled)
+	assert.Equal(1, calls)
+}
+
+func TestBadgerCacheLargeBatch(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	cache := newTestBadgerCache(t, BadgerConfig{InMemory: true})
+	defer cache.Close(ctx)
+
+	// long keys make both the writes and the deletes larger than a single transaction allows
+	values := make(map[string]string, 300)
+	keys := make([]string, 0, 300)
+	prefix := strings.Repeat("k", 48<<10)
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("%s%d", prefix, i)
+		values[key] = "v"
+		keys = append(keys, key)
+	}
+	assert.Nil(MSet(ctx, cache, "big", values, time.Minute))
+	n, err := cache.(NamespaceCacher).Count(ctx, "big")
+	assert.Nil(err)
+	assert.Equal(int64(300), n)
+
+	assert.Nil(MDelete(ctx, cache, "big", keys...))
+	n, err = cache.(NamespaceCacher).Count(ctx, "big")
+	assert.Nil(err)
+	assert.Equal(int64(0), n)
+}
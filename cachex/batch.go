@@ -0,0 +1,62 @@
+package cachex
+
+import (
+	"context"
+	"time"
+)
+
+// BatchCacher 定义批量操作接口,内置的 cache 驱动均已实现
+type BatchCacher interface {
+	// MGet 批量获取,返回结果仅包含存在的 key
+	MGet(ctx context.Context, ns string, keys ...string) (map[string]string, error)
+	// MSet 批量设置
+	MSet(ctx context.Context, ns string, values map[string]string, expiration ...time.Duration) error
+	// MDelete 批量删除
+	MDelete(ctx context.Context, ns string, keys ...string) error
+}
+
+// MGet 批量获取,cache 未实现 BatchCacher 时逐个获取
+func MGet(ctx context.Context, cache Cacher, ns string, keys ...string) (map[string]string, error) {
+	if bc, ok := cache.(BatchCacher); ok {
+		return bc.MGet(ctx, ns, keys...)
+	}
+
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		value, ok, err := cache.Get(ctx, ns, key)
+		if err != nil {
+			return nil, err
+		} else if ok {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// MSet 批量设置,cache 未实现 BatchCacher 时逐个设置
+func MSet(ctx context.Context, cache Cacher, ns string, values map[string]string, expiration ...time.Duration) error {
+	if bc, ok := cache.(BatchCacher); ok {
+		return bc.MSet(ctx, ns, values, expiration...)
+	}
+
+	for key, value := range values {
+		if err := cache.Set(ctx, ns, key, value, expiration...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MDelete 批量删除,cache 未实现 BatchCacher 时逐个删除
+func MDelete(ctx context.Context, cache Cacher, ns string, keys ...string) error {
+	if bc, ok := cache.(BatchCacher); ok {
+		return bc.MDelete(ctx, ns, keys...)
+	}
+
+	for _, key := range keys {
+		if err := cache.Delete(ctx, ns, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
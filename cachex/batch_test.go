@@ -0,0 +1,46 @@
+package cachex
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testBatchCacher(t *testing.T, cache Cacher) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	bc, ok := cache.(BatchCacher)
+	assert.True(ok)
+
+	err := bc.MSet(ctx, "batch", map[string]string{"a": "1", "b": "2", "c": "3"}, time.Minute)
+	assert.Nil(err)
+
+	values, err := bc.MGet(ctx, "batch", "a", "b", "x")
+	assert.Nil(err)
+	assert.Equal(map[string]string{"a": "1", "b": "2"}, values)
+
+	assert.Nil(bc.MDelete(ctx, "batch", "a", "c", "x"))
+	values, err = MGet(ctx, cache, "batch", "a", "b", "c")
+	assert.Nil(err)
+	assert.Equal(map[string]string{"b": "2"}, values)
+
+	assert.Nil(cache.Close(ctx))
+}
+
+func TestBatchCacher(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testBatchCacher(t, NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute}))
+	})
+	t.Run("badger", func(t *testing.T) {
+		testBatchCacher(t, newTestBadgerCache(t, BadgerConfig{Path: t.TempDir()}))
+	})
+	t.Run("redis", func(t *testing.T) {
+		testBatchCacher(t, NewRedisCacheWithClient(newTestRedisClient(t)))
+	})
+	t.Run("twolevel", func(t *testing.T) {
+		testBatchCacher(t, NewTwoLevelCacheWithClient(newTestRedisClient(t), TwoLevelConfig{}))
+	})
+}
@@ -0,0 +1,509 @@
+package cachex
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EvictionPolicy 容量超限时的淘汰策略
+type EvictionPolicy int
+
+const (
+	LRU EvictionPolicy = iota // 淘汰最久未访问的 key
+	LFU                       // 淘汰访问次数最少的 key,次数相同时淘汰最久未访问的
+)
+
+// ErrValueTooLarge 单个值超过 MaxBytes
+var ErrValueTooLarge = errors.New("cachex: value exceeds max bytes")
+
+type BoundedMemoryConfig struct {
+	MaxEntries      int                         // 最大条目数,0 表示不限制
+	MaxBytes        int64                       // 最大占用字节数(key 与 value 长度之和),0 表示不限制
+	Policy          EvictionPolicy              // 淘汰策略,默认 LRU
+	CleanupInterval time.Duration               // 过期清理间隔,0 表示只在访问时清理
+	OnEvict         func(ns, key, value string) // 因容量超限或过期被淘汰时回调,在锁外执行
+}
+
+// CacheStats 缓存统计
+type CacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Entries   int64 `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+}
+
+// StatsCacher 定义缓存统计接口
+type StatsCacher interface {
+	Stats() CacheStats
+}
+
+// NewBoundedMemoryCache Create memory cache with max entries/bytes and LRU/LFU eviction
+func NewBoundedMemoryCache(cfg BoundedMemoryConfig, opts ...Option) Cacher {
+	defaultOpts := &options{
+		Delimiter: defaultDelimiter,
+	}
+
+	for _, o := range opts {
+		o(defaultOpts)
+	}
+
+	a := &boundedCache{
+		opts:  defaultOpts,
+		cfg:   cfg,
+		items: make(map[string]*boundedEntry),
+		stop:  make(chan struct{}),
+	}
+	a.queue.policy = cfg.Policy
+	if cfg.CleanupInterval > 0 {
+		go a.janitor(cfg.CleanupInterval)
+	}
+	return a
+}
+
+type boundedEntry struct {
+	ns, key   string
+	value     string
+	expiresAt time.Time
+	freq      int64
+	seq       int64 // 最近一次访问序号
+	index     int   // 在淘汰队列中的位置
+}
+
+func (e *boundedEntry) size() int64 {
+	return int64(len(e.ns) + len(e.key) + len(e.value))
+}
+
+func (e *boundedEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+// evictQueue 淘汰队列,堆顶为下一个被淘汰的 key
+type evictQueue struct {
+	policy  EvictionPolicy
+	entries []*boundedEntry
+}
+
+func (q *evictQueue) Len() int { return len(q.entries) }
+
+func (q *evictQueue) Less(i, j int) bool {
+	a, b := q.entries[i], q.entries[j]
+	if q.policy == LFU && a.freq != b.freq {
+		return a.freq < b.freq
+	}
+	return a.seq < b.seq
+}
+
+func (q *evictQueue) Swap(i, j int) {
+	q.entries[i], q.entries[j] = q.entries[j], q.entries[i]
+	q.entries[i].index = i
+	q.entries[j].index = j
+}
+
+func (q *evictQueue) Push(x interface{}) {
+	e := x.(*boundedEntry)
+	e.index = len(q.entries)
+	q.entries = append(q.entries, e)
+}
+
+func (q *evictQueue) Pop() interface{} {
+	n := len(q.entries)
+	e := q.entries[n-1]
+	q.entries[n-1] = nil
+	q.entries = q.entries[:n-1]
+	return e
+}
+
+type boundedCache struct {
+	opts  *options
+	cfg   BoundedMemoryConfig
+	mu    sync.Mutex
+	items map[string]*boundedEntry
+	queue evictQueue
+	seq   int64
+	bytes int64
+	stats CacheStats
+	stop  chan struct{}
+	once  sync.Once
+}
+
+func (a *boundedCache) getKey(ns, key string) string {
+	return fmt.Sprintf("%s%s%s", ns, a.opts.Delimiter, key)
+}
+
+func (a *boundedCache) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			a.mu.Lock()
+			now := time.Now()
+			var evicted []*boundedEntry
+			for _, e := range a.items {
+				if e.expired(now) {
+					a.remove(e)
+					evicted = append(evicted, e)
+				}
+			}
+			a.stats.Evictions += int64(len(evicted))
+			a.mu.Unlock()
+			a.notify(evicted)
+		}
+	}
+}
+
+func (a *boundedCache) notify(evicted []*boundedEntry) {
+	if a.cfg.OnEvict == nil {
+		return
+	}
+	for _, e := range evicted {
+		a.cfg.OnEvict(e.ns, e.key, e.value)
+	}
+}
+
+// lookup 查找未过期的 key,已过期的 key 会被淘汰,调用方需持有锁
+func (a *boundedCache) lookup(ns, key string, evicted *[]*boundedEntry) (*boundedEntry, bool) {
+	e, ok := a.items[a.getKey(ns, key)]
+	if !ok {
+		return nil, false
+	}
+	if e.expired(time.Now()) {
+		a.remove(e)
+		a.stats.Evictions++
+		*evicted = append(*evicted, e)
+		return nil, false
+	}
+	return e, true
+}
+
+func (a *boundedCache) touch(e *boundedEntry) {
+	a.seq++
+	e.seq = a.seq
+	e.freq++
+	heap.Fix(&a.queue, e.index)
+}
+
+func (a *boundedCache) remove(e *boundedEntry) {
+	heap.Remove(&a.queue, e.index)
+	delete(a.items, a.getKey(e.ns, e.key))
+	a.bytes -= e.size()
+}
+
+// set 写入 key 并按容量淘汰,调用方需持有锁
+func (a *boundedCache) set(ns, key, value string, expiration time.Duration, evicted *[]*boundedEntry) error {
+	var expiresAt time.Time
+	if expiration > 0 {
+		expiresAt = time.Now().Add(expiration)
+	}
+	return a.setWithExpiresAt(ns, key, value, expiresAt, evicted)
+}
+
+func (a *boundedCache) setWithExpiresAt(ns, key, value string, expiresAt time.Time, evicted *[]*boundedEntry) error {
+	e := &boundedEntry{ns: ns, key: key, value: value, expiresAt: expiresAt}
+	if a.cfg.MaxBytes > 0 && e.size() > a.cfg.MaxBytes {
+		return ErrValueTooLarge
+	}
+
+	if old, ok := a.items[a.getKey(ns, key)]; ok {
+		a.remove(old)
+		e.freq = old.freq
+	}
+	e.freq++
+
+	// 先淘汰其他 key 再写入,避免 LFU 下新写入的 key 被立即淘汰
+	for a.queue.Len() > 0 &&
+		((a.cfg.MaxEntries > 0 && len(a.items)+1 > a.cfg.MaxEntries) ||
+			(a.cfg.MaxBytes > 0 && a.bytes+e.size() > a.cfg.MaxBytes)) {
+		victim := a.queue.entries[0]
+		a.remove(victim)
+		a.stats.Evictions++
+		*evicted = append(*evicted, victim)
+	}
+
+	a.seq++
+	e.seq = a.seq
+	a.items[a.getKey(ns, key)] = e
+	heap.Push(&a.queue, e)
+	a.bytes += e.size()
+	return nil
+}
+
+func (a *boundedCache) Stats() CacheStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stats := a.stats
+	stats.Entries = int64(len(a.items))
+	stats.Bytes = a.bytes
+	return stats
+}
+
+func (a *boundedCache) Set(ctx context.Context, ns, key, value string, expiration ...time.Duration) error {
+	var exp time.Duration
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+
+	var evicted []*boundedEntry
+	a.mu.Lock()
+	err := a.set(ns, key, value, exp, &evicted)
+	a.mu.Unlock()
+	a.notify(evicted)
+	return err
+}
+
+func (a *boundedCache) Get(ctx context.Context, ns, key string) (string, bool, error) {
+	var evicted []*boundedEntry
+	a.mu.Lock()
+	e, ok := a.lookup(ns, key, &evicted)
+	var value string
+	if ok {
+		a.touch(e)
+		a.stats.Hits++
+		value = e.value
+	} else {
+		a.stats.Misses++
+	}
+	a.mu.Unlock()
+	a.notify(evicted)
+	return value, ok, nil
+}
+
+func (a *boundedCache) GetAndDelete(ctx context.Context, ns, key string) (string, bool, error) {
+	var evicted []*boundedEntry
+	a.mu.Lock()
+	e, ok := a.lookup(ns, key, &evicted)
+	var value string
+	if ok {
+		a.remove(e)
+		a.stats.Hits++
+		value = e.value
+	} else {
+		a.stats.Misses++
+	}
+	a.mu.Unlock()
+	a.notify(evicted)
+	return value, ok, nil
+}
+
+func (a *boundedCache) Exists(ctx context.Context, ns, key string) (bool, error) {
+	var evicted []*boundedEntry
+	a.mu.Lock()
+	_, ok := a.lookup(ns, key, &evicted)
+	a.mu.Unlock()
+	a.notify(evicted)
+	return ok, nil
+}
+
+func (a *boundedCache) Delete(ctx context.Context, ns, key string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if e, ok := a.items[a.getKey(ns, key)]; ok {
+		a.remove(e)
+	}
+	return nil
+}
+
+func (a *boundedCache) MGet(ctx context.Context, ns string, keys ...string) (map[string]string, error) {
+	var evicted []*boundedEntry
+	values := make(map[string]string, len(keys))
+	a.mu.Lock()
+	for _, key := range keys {
+		if e, ok := a.lookup(ns, key, &evicted); ok {
+			a.touch(e)
+			a.stats.Hits++
+			values[key] = e.value
+		} else {
+			a.stats.Misses++
+		}
+	}
+	a.mu.Unlock()
+	a.notify(evicted)
+	return values, nil
+}
+
+func (a *boundedCache) MSet(ctx context.Context, ns string, values map[string]string, expiration ...time.Duration) error {
+	var exp time.Duration
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+
+	var (
+		evicted []*boundedEntry
+		err     error
+	)
+	a.mu.Lock()
+	for key, value := range values {
+		if err = a.set(ns, key, value, exp, &evicted); err != nil {
+			break
+		}
+	}
+	a.mu.Unlock()
+	a.notify(evicted)
+	return err
+}
+
+func (a *boundedCache) MDelete(ctx context.Context, ns string, keys ...string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, key := range keys {
+		if e, ok := a.items[a.getKey(ns, key)]; ok {
+			a.remove(e)
+		}
+	}
+	return nil
+}
+
+func (a *boundedCache) Incr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	return a.IncrBy(ctx, ns, key, 1, expiration...)
+}
+
+func (a *boundedCache) Decr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	return a.IncrBy(ctx, ns, key, -1, expiration...)
+}
+
+func (a *boundedCache) IncrBy(ctx context.Context, ns, key string, value int64, expiration ...time.Duration) (int64, error) {
+	var (
+		evicted   []*boundedEntry
+		expiresAt time.Time
+	)
+	if len(expiration) > 0 && expiration[0] > 0 {
+		expiresAt = time.Now().Add(expiration[0])
+	}
+
+	a.mu.Lock()
+	if e, ok := a.lookup(ns, key, &evicted); ok {
+		n, err := strconv.ParseInt(e.value, 10, 64)
+		if err != nil {
+			a.mu.Unlock()
+			a.notify(evicted)
+			return 0, ErrNotInteger
+		}
+		value += n
+		expiresAt = e.expiresAt
+	}
+	err := a.setWithExpiresAt(ns, key, strconv.FormatInt(value, 10), expiresAt, &evicted)
+	a.mu.Unlock()
+	a.notify(evicted)
+	return value, err
+}
+
+func (a *boundedCache) SetNX(ctx context.Context, ns, key, value string, expiration ...time.Duration) (bool, error) {
+	var exp time.Duration
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+
+	var evicted []*boundedEntry
+	a.mu.Lock()
+	_, exists := a.lookup(ns, key, &evicted)
+	var err error
+	if !exists {
+		err = a.set(ns, key, value, exp, &evicted)
+	}
+	a.mu.Unlock()
+	a.notify(evicted)
+	return !exists && err == nil, err
+}
+
+func (a *boundedCache) TTL(ctx context.Context, ns, key string) (time.Duration, bool, error) {
+	var evicted []*boundedEntry
+	a.mu.Lock()
+	e, ok := a.lookup(ns, key, &evicted)
+	ttl := NoExpiration
+	if ok && !e.expiresAt.IsZero() {
+		ttl = time.Until(e.expiresAt)
+	}
+	a.mu.Unlock()
+	a.notify(evicted)
+	if !ok {
+		return 0, false, nil
+	}
+	return ttl, true, nil
+}
+
+func (a *boundedCache) Expire(ctx context.Context, ns, key string, expiration time.Duration) (bool, error) {
+	var evicted []*boundedEntry
+	a.mu.Lock()
+	e, ok := a.lookup(ns, key, &evicted)
+	if ok {
+		e.expiresAt = time.Time{}
+		if expiration > 0 {
+			e.expiresAt = time.Now().Add(expiration)
+		}
+	}
+	a.mu.Unlock()
+	a.notify(evicted)
+	return ok, nil
+}
+
+// snapshot 返回命名空间下未过期的 key/value,遍历时不持有锁
+func (a *boundedCache) snapshot(ns string) []*boundedEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	entries := make([]*boundedEntry, 0)
+	for _, e := range a.items {
+		if e.ns == ns && !e.expired(now) {
+			entries = append(entries, &boundedEntry{ns: e.ns, key: e.key, value: e.value})
+		}
+	}
+	return entries
+}
+
+func (a *boundedCache) Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error {
+	for _, e := range a.snapshot(ns) {
+		if !fn(ctx, e.key, e.value) {
+			break
+		}
+	}
+	return nil
+}
+
+func (a *boundedCache) ClearNamespace(ctx context.Context, ns string, progress ...ProgressFunc) (int64, error) {
+	a.mu.Lock()
+	var deleted int64
+	for _, e := range a.items {
+		if e.ns == ns {
+			a.remove(e)
+			deleted++
+		}
+	}
+	a.mu.Unlock()
+	reportProgress(progress, deleted)
+	return deleted, nil
+}
+
+func (a *boundedCache) Count(ctx context.Context, ns string) (int64, error) {
+	return int64(len(a.snapshot(ns))), nil
+}
+
+func (a *boundedCache) IteratorMatch(ctx context.Context, ns, pattern string, fn func(ctx context.Context, key, value string) bool) error {
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return err
+	}
+	return a.Iterator(ctx, ns, func(ctx context.Context, key, value string) bool {
+		if !re.MatchString(key) {
+			return true
+		}
+		return fn(ctx, key, value)
+	})
+}
+
+func (a *boundedCache) Close(ctx context.Context) error {
+	a.once.Do(func() { close(a.stop) })
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.items = make(map[string]*boundedEntry)
+	a.queue.entries = nil
+	a.bytes = 0
+	return nil
+}
@@ -0,0 +1,130 @@
+package cachex
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoundedMemoryCache(t *testing.T) {
+	t.Run("batch", func(t *testing.T) {
+		testBatchCacher(t, NewBoundedMemoryCache(BoundedMemoryConfig{}))
+	})
+	t.Run("atomic", func(t *testing.T) {
+		testAtomicCacher(t, NewBoundedMemoryCache(BoundedMemoryConfig{}))
+	})
+	t.Run("namespace", func(t *testing.T) {
+		testNamespaceCacher(t, NewBoundedMemoryCache(BoundedMemoryConfig{}))
+	})
+}
+
+func TestBoundedMemoryCacheLRU(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	var evicted []string
+	cache := NewBoundedMemoryCache(BoundedMemoryConfig{
+		MaxEntries: 3,
+		OnEvict: func(ns, key, value string) {
+			evicted = append(evicted, key)
+		},
+	})
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(cache.Set(ctx, "lru", fmt.Sprintf("k%d", i), "v"))
+	}
+	_, ok, _ := cache.Get(ctx, "lru", "k0")
+	assert.True(ok)
+
+	assert.Nil(cache.Set(ctx, "lru", "k3", "v"))
+	assert.Equal([]string{"k1"}, evicted)
+
+	_, ok, _ = cache.Get(ctx, "lru", "k1")
+	assert.False(ok)
+
+	stats := cache.(StatsCacher).Stats()
+	assert.Equal(CacheStats{Hits: 1, Misses: 1, Evictions: 1, Entries: 3, Bytes: 3 * int64(len("lru")+len("k0")+len("v"))}, stats)
+	assert.Nil(cache.Close(ctx))
+}
+
+func TestBoundedMemoryCacheLFU(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	var evicted []string
+	cache := NewBoundedMemoryCache(BoundedMemoryConfig{
+		MaxEntries: 3,
+		Policy:     LFU,
+		OnEvict: func(ns, key, value string) {
+			evicted = append(evicted, key)
+		},
+	})
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(cache.Set(ctx, "lfu", fmt.Sprintf("k%d", i), "v"))
+	}
+	for i := 0; i < 3; i++ {
+		_, _, _ = cache.Get(ctx, "lfu", "k0")
+		_, _, _ = cache.Get(ctx, "lfu", "k2")
+	}
+	_, _, _ = cache.Get(ctx, "lfu", "k1")
+
+	// k1 is the most recently used but the least frequently used
+	assert.Nil(cache.Set(ctx, "lfu", "k3", "v"))
+	assert.Equal([]string{"k1"}, evicted)
+	assert.Nil(cache.Close(ctx))
+}
+
+func TestBoundedMemoryCacheMaxBytes(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	cache := NewBoundedMemoryCache(BoundedMemoryConfig{MaxBytes: 20})
+	assert.Nil(cache.Set(ctx, "b", "k1", "0123456789"))
+	assert.Nil(cache.Set(ctx, "b", "k2", "0123456789"))
+
+	ok, _ := cache.Exists(ctx, "b", "k1")
+	assert.False(ok)
+	ok, _ = cache.Exists(ctx, "b", "k2")
+	assert.True(ok)
+	assert.Equal(int64(13), cache.(StatsCacher).Stats().Bytes)
+
+	assert.ErrorIs(cache.Set(ctx, "b", "k3", "012345678901234567890"), ErrValueTooLarge)
+	assert.Nil(cache.Close(ctx))
+}
+
+func TestBoundedMemoryCacheExpiration(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	var (
+		mu      sync.Mutex
+		evicted []string
+	)
+	cache := NewBoundedMemoryCache(BoundedMemoryConfig{
+		CleanupInterval: 10 * time.Millisecond,
+		OnEvict: func(ns, key, value string) {
+			mu.Lock()
+			defer mu.Unlock()
+			evicted = append(evicted, key)
+		},
+	})
+	assert.Nil(cache.Set(ctx, "exp", "foo", "bar", 20*time.Millisecond))
+	assert.Nil(cache.Set(ctx, "exp", "keep", "bar"))
+
+	assert.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(evicted) == 1 && evicted[0] == "foo"
+	}, time.Second, 10*time.Millisecond)
+
+	_, ok, _ := cache.Get(ctx, "exp", "foo")
+	assert.False(ok)
+	_, ok, _ = cache.Get(ctx, "exp", "keep")
+	assert.True(ok)
+	assert.Nil(cache.Close(ctx))
+}
@@ -0,0 +1,53 @@
+package cachex
+
+import (
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/gopkg-dev/karma/encoding/gob"
+	"github.com/gopkg-dev/karma/encoding/json"
+)
+
+// Codec 定义缓存值的编解码
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// JSONCodec 使用 JSON 编解码
+	JSONCodec Codec = jsonCodec{}
+	// GobCodec 使用 gob 编解码
+	GobCodec Codec = gobCodec{}
+	// BinaryCodec 使用 MessagePack 编解码,比 JSON 更紧凑,结构体字段名取 msgpack tag
+	BinaryCodec Codec = binaryCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	return gob.Marshal(v)
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.Unmarshal(data, v)
+}
+
+type binaryCodec struct{}
+
+func (binaryCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (binaryCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
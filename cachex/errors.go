@@ -0,0 +1,31 @@
+package cachex
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrEncode 缓存值编码失败
+	ErrEncode = errors.New("cachex: encode failed")
+	// ErrDecode 缓存值解码失败
+	ErrDecode = errors.New("cachex: decode failed")
+	// ErrUnsupported 被包装的 cache 不支持该操作,同时匹配 errors.ErrUnsupported
+	ErrUnsupported = fmt.Errorf("cachex: operation not supported: %w", errors.ErrUnsupported)
+)
+
+// CodecError 缓存值编解码错误,可通过 errors.Is(err, ErrEncode/ErrDecode) 判断
+type CodecError struct {
+	Op  error // ErrEncode 或 ErrDecode
+	NS  string
+	Key string
+	Err error
+}
+
+func (e *CodecError) Error() string {
+	return fmt.Sprintf("%v: %s%s%s: %v", e.Op, e.NS, defaultDelimiter, e.Key, e.Err)
+}
+
+func (e *CodecError) Unwrap() []error {
+	return []error{e.Op, e.Err}
+}
@@ -0,0 +1,209 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/gopkg-dev/karma/encoding/json"
+	"github.com/gopkg-dev/karma/log"
+)
+
+// ErrNotFound 由 LoadFunc 返回表示数据不存在,结果会按 NegativeTTL 缓存
+var ErrNotFound = errors.New("cachex: not found")
+
+// LoadFunc 缓存未命中时加载数据
+type LoadFunc[T any] func(ctx context.Context) (T, error)
+
+type loaderOptions struct {
+	codec       Codec
+	ttl         time.Duration
+	negativeTTL time.Duration
+	staleTTL    time.Duration
+	beta        float64
+}
+
+type LoaderOption func(*loaderOptions)
+
+// WithLoaderCodec 设置缓存值编解码,默认 JSONCodec
+func WithLoaderCodec(codec Codec) LoaderOption {
+	return func(o *loaderOptions) { o.codec = codec }
+}
+
+// WithLoaderTTL 设置缓存有效期
+func WithLoaderTTL(d time.Duration) LoaderOption {
+	return func(o *loaderOptions) { o.ttl = d }
+}
+
+// WithNegativeTTL 设置"不存在"结果的缓存有效期,0 表示不缓存
+func WithNegativeTTL(d time.Duration) LoaderOption {
+	return func(o *loaderOptions) { o.negativeTTL = d }
+}
+
+// WithStaleTTL 设置过期后仍保留旧值的时长,期间加载失败时返回旧值
+func WithStaleTTL(d time.Duration) LoaderOption {
+	return func(o *loaderOptions) { o.staleTTL = d }
+}
+
+// WithEarlyRefresh 设置提前刷新系数(XFetch),越大越早刷新,0 表示不提前刷新
+func WithEarlyRefresh(beta float64) LoaderOption {
+	return func(o *loaderOptions) { o.beta = beta }
+}
+
+// Loader 旁路缓存加载器,同一 key 的并发未命中只会调用一次 LoadFunc
+type Loader[T any] struct {
+	cache Cacher
+	ns    string
+	opts  *loaderOptions
+	group singleflight.Group
+}
+
+// NewLoader 创建旁路缓存加载器
+func NewLoader[T any](cache Cacher, ns string, opts ...LoaderOption) *Loader[T] {
+	o := &loaderOptions{
+		codec: JSONCodec,
+		ttl:   time.Minute,
+		beta:  1,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Loader[T]{
+		cache: cache,
+		ns:    ns,
+		opts:  o,
+	}
+}
+
+// loaderEntry 缓存中保存的数据及元信息
+type loaderEntry struct {
+	Value    []byte `json:"v,omitempty"`
+	NotFound bool   `json:"n,omitempty"`
+	Expire   int64  `json:"e"` // 逻辑过期时间(UnixNano)
+	Delta    int64  `json:"d"` // 加载耗时(纳秒)
+}
+
+// Get 获取缓存值,未命中或已过期时通过 fn 加载
+func (l *Loader[T]) Get(ctx context.Context, key string, fn LoadFunc[T]) (T, error) {
+	e, ok, err := l.read(ctx, key)
+	if err != nil {
+		var zero T
+		return zero, err
+	} else if !ok {
+		return l.load(ctx, key, fn)
+	}
+
+	now := time.Now()
+	if now.UnixNano() < e.Expire {
+		if l.shouldRefresh(e, now) {
+			go func() {
+				if _, err := l.load(context.WithoutCancel(ctx), key, fn); err != nil && !errors.Is(err, ErrNotFound) {
+					log.Context(ctx).Warnf("cachex: early refresh %s%s%s: %v", l.ns, defaultDelimiter, key, err)
+				}
+			}()
+		}
+		return l.decode(key, e)
+	}
+
+	// 已过期但仍在 stale 窗口内
+	value, err := l.load(ctx, key, fn)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		log.Context(ctx).Warnf("cachex: reload %s%s%s failed, serving stale value: %v", l.ns, defaultDelimiter, key, err)
+		return l.decode(key, e)
+	}
+	return value, err
+}
+
+// Delete 删除缓存值
+func (l *Loader[T]) Delete(ctx context.Context, key string) error {
+	return l.cache.Delete(ctx, l.ns, key)
+}
+
+func (l *Loader[T]) read(ctx context.Context, key string) (*loaderEntry, bool, error) {
+	s, ok, err := l.cache.Get(ctx, l.ns, key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	var e loaderEntry
+	if err := json.UnmarshalString(s, &e); err != nil {
+		// 无法识别的数据视为未命中,重新加载后覆盖
+		return nil, false, nil
+	}
+	return &e, true, nil
+}
+
+func (l *Loader[T]) decode(key string, e *loaderEntry) (T, error) {
+	var value T
+	if e.NotFound {
+		return value, ErrNotFound
+	}
+	if err := l.opts.codec.Unmarshal(e.Value, &value); err != nil {
+		return value, &CodecError{Op: ErrDecode, NS: l.ns, Key: key, Err: err}
+	}
+	return value, nil
+}
+
+// load 合并同一 key 的并发加载,fn 不随任一调用方取消,各调用方按自己的 ctx 等待结果
+func (l *Loader[T]) load(ctx context.Context, key string, fn LoadFunc[T]) (T, error) {
+	ch := l.group.DoChan(key, func() (interface{}, error) {
+		ctx := context.WithoutCancel(ctx)
+		start := time.Now()
+		value, err := fn(ctx)
+		delta := time.Since(start)
+
+		if errors.Is(err, ErrNotFound) {
+			if l.opts.negativeTTL > 0 {
+				l.write(ctx, key, &loaderEntry{NotFound: true}, l.opts.negativeTTL, delta)
+			}
+			return value, err
+		} else if err != nil {
+			return value, err
+		}
+
+		b, err := l.opts.codec.Marshal(&value)
+		if err != nil {
+			return value, &CodecError{Op: ErrEncode, NS: l.ns, Key: key, Err: err}
+		}
+		l.write(ctx, key, &loaderEntry{Value: b}, l.opts.ttl, delta)
+		return value, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	case r := <-ch:
+		value, _ := r.Val.(T)
+		return value, r.Err
+	}
+}
+
+func (l *Loader[T]) write(ctx context.Context, key string, e *loaderEntry, ttl, delta time.Duration) {
+	e.Expire = time.Now().Add(ttl).UnixNano()
+	e.Delta = int64(delta)
+
+	b, err := json.Marshal(e)
+	if err == nil {
+		err = l.cache.Set(ctx, l.ns, key, string(b), ttl+l.opts.staleTTL)
+	}
+	if err != nil {
+		log.Context(ctx).Warnf("cachex: write %s%s%s: %v", l.ns, defaultDelimiter, key, err)
+	}
+}
+
+// shouldRefresh 按 XFetch 算法判断是否提前刷新: now - delta*beta*ln(rand) >= expire
+func (l *Loader[T]) shouldRefresh(e *loaderEntry, now time.Time) bool {
+	if l.opts.beta <= 0 || e.Delta <= 0 || e.NotFound {
+		return false
+	}
+	r := rand.Float64()
+	if r == 0 {
+		return true
+	}
+	gap := float64(e.Delta) * l.opts.beta * -math.Log(r)
+	return float64(now.UnixNano())+gap >= float64(e.Expire)
+}
@@ -0,0 +1,143 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoader(t *testing.T) {
+	assert := assert.New(t)
+
+	cache := NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})
+	ctx := context.Background()
+
+	var calls int32
+	loader := NewLoader[string](cache, "user",
+		WithLoaderTTL(50*time.Millisecond),
+		WithStaleTTL(time.Minute),
+		WithNegativeTTL(time.Minute),
+		WithEarlyRefresh(0),
+	)
+	fn := func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return "foo", nil
+	}
+
+	// concurrent misses are coalesced
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := loader.Get(ctx, "1", fn)
+			assert.Nil(err)
+			assert.Equal("foo", val)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+
+	// stale value is served when reload fails
+	time.Sleep(60 * time.Millisecond)
+	val, err := loader.Get(ctx, "1", func(ctx context.Context) (string, error) {
+		return "", errors.New("db down")
+	})
+	assert.Nil(err)
+	assert.Equal("foo", val)
+
+	// not found results are cached
+	calls = 0
+	notFound := func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		return "", ErrNotFound
+	}
+	_, err = loader.Get(ctx, "2", notFound)
+	assert.ErrorIs(err, ErrNotFound)
+	_, err = loader.Get(ctx, "2", notFound)
+	assert.ErrorIs(err, ErrNotFound)
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+
+	assert.Nil(loader.Delete(ctx, "2"))
+	val, err = loader.Get(ctx, "2", fn)
+	assert.Nil(err)
+	assert.Equal("foo", val)
+}
+
+func TestLoaderCancel(t *testing.T) {
+	assert := assert.New(t)
+
+	cache := NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})
+	loader := NewLoader[string](cache, "user", WithEarlyRefresh(0))
+
+	var once sync.Once
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (string, error) {
+		once.Do(func() { close(started) })
+		<-release
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		return "foo", nil
+	}
+
+	// the first caller gives up, the coalesced waiter still gets the value
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := loader.Get(ctx, "1", fn)
+		first <- err
+	}()
+	<-started
+
+	second := make(chan string, 1)
+	go func() {
+		val, err := loader.Get(context.Background(), "1", fn)
+		assert.Nil(err)
+		second <- val
+	}()
+
+	cancel()
+	assert.ErrorIs(<-first, context.Canceled)
+	close(release)
+	assert.Equal("foo", <-second)
+
+	val, err := loader.Get(context.Background(), "1", fn)
+	assert.Nil(err)
+	assert.Equal("foo", val)
+}
+
+func TestLoaderEarlyRefresh(t *testing.T) {
+	assert := assert.New(t)
+
+	cache := NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})
+	ctx := context.Background()
+
+	loader := NewLoader[int](cache, "counter", WithLoaderTTL(time.Minute), WithEarlyRefresh(1e9))
+	var calls int32
+	fn := func(ctx context.Context) (int, error) {
+		n := atomic.AddInt32(&calls, 1)
+		time.Sleep(time.Millisecond)
+		return int(n), nil
+	}
+
+	val, err := loader.Get(ctx, "1", fn)
+	assert.Nil(err)
+	assert.Equal(1, val)
+
+	// with a huge beta every hit triggers a background refresh
+	val, err = loader.Get(ctx, "1", fn)
+	assert.Nil(err)
+	assert.Equal(1, val)
+	assert.Eventually(func() bool {
+		val, _, _ := cache.Get(ctx, "counter", "1")
+		return val != "" && atomic.LoadInt32(&calls) >= 2
+	}, time.Second, 5*time.Millisecond)
+}
@@ -3,7 +3,9 @@ package cachex
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
@@ -32,6 +34,7 @@ func NewMemoryCache(cfg MemoryConfig, opts ...Option) Cacher {
 type memCache struct {
 	opts  *options
 	cache *cache.Cache
+	mu    sync.Mutex // 保护读-改-写操作的原子性
 }
 
 func (a *memCache) getKey(ns, key string) string {
@@ -67,15 +70,122 @@ func (a *memCache) Delete(ctx context.Context, ns, key string) error {
 }
 
 func (a *memCache) GetAndDelete(ctx context.Context, ns, key string) (string, bool, error) {
-	value, ok, err := a.Get(ctx, ns, key)
-	if err != nil {
-		return "", false, err
-	} else if !ok {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	k := a.getKey(ns, key)
+	val, ok := a.cache.Get(k)
+	if !ok {
 		return "", false, nil
 	}
+	a.cache.Delete(k)
+	return val.(string), true, nil
+}
 
-	a.cache.Delete(a.getKey(ns, key))
-	return value, true, nil
+func (a *memCache) MGet(ctx context.Context, ns string, keys ...string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if val, ok := a.cache.Get(a.getKey(ns, key)); ok {
+			values[key] = val.(string)
+		}
+	}
+	return values, nil
+}
+
+func (a *memCache) MSet(ctx context.Context, ns string, values map[string]string, expiration ...time.Duration) error {
+	var exp time.Duration
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+
+	for key, value := range values {
+		a.cache.Set(a.getKey(ns, key), value, exp)
+	}
+	return nil
+}
+
+func (a *memCache) MDelete(ctx context.Context, ns string, keys ...string) error {
+	for _, key := range keys {
+		a.cache.Delete(a.getKey(ns, key))
+	}
+	return nil
+}
+
+// remaining 返回剩余有效期,永不过期时返回 cache.NoExpiration
+func (a *memCache) remaining(expiresAt time.Time) time.Duration {
+	if expiresAt.IsZero() {
+		return cache.NoExpiration
+	}
+	return time.Until(expiresAt)
+}
+
+func (a *memCache) Incr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	return a.IncrBy(ctx, ns, key, 1, expiration...)
+}
+
+func (a *memCache) Decr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	return a.IncrBy(ctx, ns, key, -1, expiration...)
+}
+
+func (a *memCache) IncrBy(ctx context.Context, ns, key string, value int64, expiration ...time.Duration) (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	k := a.getKey(ns, key)
+	exp := cache.NoExpiration
+	if len(expiration) > 0 && expiration[0] > 0 {
+		exp = expiration[0]
+	}
+
+	if val, expiresAt, ok := a.cache.GetWithExpiration(k); ok {
+		n, err := strconv.ParseInt(val.(string), 10, 64)
+		if err != nil {
+			return 0, ErrNotInteger
+		}
+		value += n
+		exp = a.remaining(expiresAt)
+	}
+
+	a.cache.Set(k, strconv.FormatInt(value, 10), exp)
+	return value, nil
+}
+
+func (a *memCache) SetNX(ctx context.Context, ns, key, value string, expiration ...time.Duration) (bool, error) {
+	var exp time.Duration
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+
+	if err := a.cache.Add(a.getKey(ns, key), value, exp); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+func (a *memCache) TTL(ctx context.Context, ns, key string) (time.Duration, bool, error) {
+	_, expiresAt, ok := a.cache.GetWithExpiration(a.getKey(ns, key))
+	if !ok {
+		return 0, false, nil
+	} else if expiresAt.IsZero() {
+		return NoExpiration, true, nil
+	}
+	return time.Until(expiresAt), true, nil
+}
+
+func (a *memCache) Expire(ctx context.Context, ns, key string, expiration time.Duration) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	k := a.getKey(ns, key)
+	val, ok := a.cache.Get(k)
+	if !ok {
+		return false, nil
+	}
+	if expiration <= 0 {
+		expiration = cache.NoExpiration
+	}
+	a.cache.Set(k, val, expiration)
+	return true, nil
 }
 
 func (a *memCache) Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error {
@@ -89,6 +199,52 @@ func (a *memCache) Iterator(ctx context.Context, ns string, fn func(ctx context.
 	return nil
 }
 
+const memClearBatchSize = 1000
+
+func (a *memCache) ClearNamespace(ctx context.Context, ns string, progress ...ProgressFunc) (int64, error) {
+	prefix := a.getKey(ns, "")
+	var deleted int64
+	for k := range a.cache.Items() {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		a.cache.Delete(k)
+		deleted++
+		if deleted%memClearBatchSize == 0 {
+			if err := ctx.Err(); err != nil {
+				return deleted, err
+			}
+			reportProgress(progress, deleted)
+		}
+	}
+	reportProgress(progress, deleted)
+	return deleted, nil
+}
+
+func (a *memCache) Count(ctx context.Context, ns string) (int64, error) {
+	prefix := a.getKey(ns, "")
+	var n int64
+	for k := range a.cache.Items() {
+		if strings.HasPrefix(k, prefix) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (a *memCache) IteratorMatch(ctx context.Context, ns, pattern string, fn func(ctx context.Context, key, value string) bool) error {
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return err
+	}
+	return a.Iterator(ctx, ns, func(ctx context.Context, key, value string) bool {
+		if !re.MatchString(key) {
+			return true
+		}
+		return fn(ctx, key, value)
+	})
+}
+
 func (a *memCache) Close(ctx context.Context) error {
 	a.cache.Flush()
 	return nil
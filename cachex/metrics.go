@@ -0,0 +1,348 @@
+package cachex
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gopkg-dev/karma/log"
+	"github.com/gopkg-dev/karma/util"
+)
+
+// DefaultLatencyBuckets 缓存操作耗时直方图默认分桶
+var DefaultLatencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	time.Second,
+}
+
+type metricsOptions struct {
+	buckets       []time.Duration
+	slowThreshold time.Duration
+	logger        log.Logger
+}
+
+type MetricsOption func(*metricsOptions)
+
+// WithLatencyBuckets 设置耗时直方图分桶,默认 DefaultLatencyBuckets
+func WithLatencyBuckets(buckets ...time.Duration) MetricsOption {
+	return func(o *metricsOptions) { o.buckets = buckets }
+}
+
+// WithSlowThreshold 设置慢操作阈值,耗时超过阈值的操作记录 Warn 日志,0 表示不记录
+func WithSlowThreshold(d time.Duration) MetricsOption {
+	return func(o *metricsOptions) { o.slowThreshold = d }
+}
+
+// WithMetricsLogger 设置慢操作日志记录器,默认使用全局日志
+func WithMetricsLogger(logger log.Logger) MetricsOption {
+	return func(o *metricsOptions) { o.logger = logger }
+}
+
+// LatencyBucket 直方图分桶,Count 为耗时小于等于 Le 的累计次数
+type LatencyBucket = util.LatencyBucket
+
+// NamespaceStats 按命名空间汇总的缓存统计,Count/Sum 为操作次数及总耗时
+type NamespaceStats struct {
+	Namespace string `json:"namespace"`
+	Hits      int64  `json:"hits"`
+	Misses    int64  `json:"misses"`
+	Errors    int64  `json:"errors"`
+	util.Histogram
+}
+
+// HitRatio 命中率,没有读操作时返回 0
+func (s NamespaceStats) HitRatio() float64 {
+	if total := s.Hits + s.Misses; total > 0 {
+		return float64(s.Hits) / float64(total)
+	}
+	return 0
+}
+
+// MetricsCache 记录命中率、错误及耗时的 Cacher 装饰器
+type MetricsCache struct {
+	Cacher
+	opts  *metricsOptions
+	mu    sync.Mutex
+	stats map[string]*NamespaceStats
+}
+
+// NewMetricsCache 包装 cache 并采集指标,cache 实现的批量/原子/命名空间接口同样会被记录,
+// 未实现的原子/命名空间操作返回 ErrUnsupported
+func NewMetricsCache(cache Cacher, opts ...MetricsOption) *MetricsCache {
+	o := &metricsOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if len(o.buckets) == 0 {
+		o.buckets = DefaultLatencyBuckets
+	}
+	o.buckets = util.SortBuckets(o.buckets)
+	if o.logger == nil {
+		o.logger = log.GetLogger()
+	}
+	return &MetricsCache{
+		Cacher: cache,
+		opts:   o,
+		stats:  make(map[string]*NamespaceStats),
+	}
+}
+
+// Snapshot 返回按命名空间排序的指标快照
+func (m *MetricsCache) Snapshot() []NamespaceStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make([]NamespaceStats, 0, len(m.stats))
+	for _, s := range m.stats {
+		stats := *s
+		stats.Histogram = s.Histogram.Clone()
+		snapshot = append(snapshot, stats)
+	}
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].Namespace < snapshot[j].Namespace })
+	return snapshot
+}
+
+// Reset 清空已采集的指标
+func (m *MetricsCache) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats = make(map[string]*NamespaceStats)
+}
+
+func (m *MetricsCache) namespace(ns string) *NamespaceStats {
+	stats, ok := m.stats[ns]
+	if !ok {
+		stats = &NamespaceStats{
+			Namespace: ns,
+			Histogram: util.NewHistogram(m.opts.buckets),
+		}
+		m.stats[ns] = stats
+	}
+	return stats
+}
+
+// observe 记录一次操作,hits/misses 为本次读取的命中及未命中数量
+func (m *MetricsCache) observe(ctx context.Context, ns, op string, start time.Time, hits, misses int, err error) {
+	elapsed := time.Since(start)
+
+	m.mu.Lock()
+	stats := m.namespace(ns)
+	stats.Observe(elapsed)
+	stats.Hits += int64(hits)
+	stats.Misses += int64(misses)
+	if err != nil {
+		stats.Errors++
+	}
+	m.mu.Unlock()
+
+	if m.opts.slowThreshold > 0 && elapsed >= m.opts.slowThreshold {
+		_ = log.WithContext(ctx, m.opts.logger).Log(log.LevelWarn,
+			log.DefaultMessageKey, "cachex: slow operation",
+			"op", op,
+			"ns", ns,
+			"elapsed", float64(elapsed.Nanoseconds())/1e6,
+		)
+	}
+}
+
+// hit 将是否命中转换为 hits/misses 计数
+func hit(ok bool, err error) (int, int) {
+	if err != nil {
+		return 0, 0
+	} else if ok {
+		return 1, 0
+	}
+	return 0, 1
+}
+
+func (m *MetricsCache) Set(ctx context.Context, ns, key, value string, expiration ...time.Duration) error {
+	start := time.Now()
+	err := m.Cacher.Set(ctx, ns, key, value, expiration...)
+	m.observe(ctx, ns, "set", start, 0, 0, err)
+	return err
+}
+
+func (m *MetricsCache) Get(ctx context.Context, ns, key string) (string, bool, error) {
+	start := time.Now()
+	value, ok, err := m.Cacher.Get(ctx, ns, key)
+	hits, misses := hit(ok, err)
+	m.observe(ctx, ns, "get", start, hits, misses, err)
+	return value, ok, err
+}
+
+func (m *MetricsCache) GetAndDelete(ctx context.Context, ns, key string) (string, bool, error) {
+	start := time.Now()
+	value, ok, err := m.Cacher.GetAndDelete(ctx, ns, key)
+	hits, misses := hit(ok, err)
+	m.observe(ctx, ns, "get_and_delete", start, hits, misses, err)
+	return value, ok, err
+}
+
+func (m *MetricsCache) Exists(ctx context.Context, ns, key string) (bool, error) {
+	start := time.Now()
+	ok, err := m.Cacher.Exists(ctx, ns, key)
+	m.observe(ctx, ns, "exists", start, 0, 0, err)
+	return ok, err
+}
+
+func (m *MetricsCache) Delete(ctx context.Context, ns, key string) error {
+	start := time.Now()
+	err := m.Cacher.Delete(ctx, ns, key)
+	m.observe(ctx, ns, "delete", start, 0, 0, err)
+	return err
+}
+
+func (m *MetricsCache) Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error {
+	start := time.Now()
+	err := m.Cacher.Iterator(ctx, ns, fn)
+	m.observe(ctx, ns, "iterator", start, 0, 0, err)
+	return err
+}
+
+func (m *MetricsCache) MGet(ctx context.Context, ns string, keys ...string) (map[string]string, error) {
+	start := time.Now()
+	values, err := MGet(ctx, m.Cacher, ns, keys...)
+	var hits, misses int
+	if err == nil {
+		hits, misses = len(values), len(keys)-len(values)
+	}
+	m.observe(ctx, ns, "mget", start, hits, misses, err)
+	return values, err
+}
+
+func (m *MetricsCache) MSet(ctx context.Context, ns string, values map[string]string, expiration ...time.Duration) error {
+	start := time.Now()
+	err := MSet(ctx, m.Cacher, ns, values, expiration...)
+	m.observe(ctx, ns, "mset", start, 0, 0, err)
+	return err
+}
+
+func (m *MetricsCache) MDelete(ctx context.Context, ns string, keys ...string) error {
+	start := time.Now()
+	err := MDelete(ctx, m.Cacher, ns, keys...)
+	m.observe(ctx, ns, "mdelete", start, 0, 0, err)
+	return err
+}
+
+func (m *MetricsCache) atomic() (AtomicCacher, error) {
+	if ac, ok := m.Cacher.(AtomicCacher); ok {
+		return ac, nil
+	}
+	return nil, fmt.Errorf("%w: %T does not implement AtomicCacher", ErrUnsupported, m.Cacher)
+}
+
+func (m *MetricsCache) Incr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	return m.IncrBy(ctx, ns, key, 1, expiration...)
+}
+
+func (m *MetricsCache) Decr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	return m.IncrBy(ctx, ns, key, -1, expiration...)
+}
+
+func (m *MetricsCache) IncrBy(ctx context.Context, ns, key string, value int64, expiration ...time.Duration) (int64, error) {
+	start := time.Now()
+	ac, err := m.atomic()
+	var n int64
+	if err == nil {
+		n, err = ac.IncrBy(ctx, ns, key, value, expiration...)
+	}
+	m.observe(ctx, ns, "incr_by", start, 0, 0, err)
+	return n, err
+}
+
+func (m *MetricsCache) SetNX(ctx context.Context, ns, key, value string, expiration ...time.Duration) (bool, error) {
+	start := time.Now()
+	ac, err := m.atomic()
+	var ok bool
+	if err == nil {
+		ok, err = ac.SetNX(ctx, ns, key, value, expiration...)
+	}
+	m.observe(ctx, ns, "setnx", start, 0, 0, err)
+	return ok, err
+}
+
+func (m *MetricsCache) TTL(ctx context.Context, ns, key string) (time.Duration, bool, error) {
+	start := time.Now()
+	ac, err := m.atomic()
+	var (
+		ttl time.Duration
+		ok  bool
+	)
+	if err == nil {
+		ttl, ok, err = ac.TTL(ctx, ns, key)
+	}
+	m.observe(ctx, ns, "ttl", start, 0, 0, err)
+	return ttl, ok, err
+}
+
+func (m *MetricsCache) Expire(ctx context.Context, ns, key string, expiration time.Duration) (bool, error) {
+	start := time.Now()
+	ac, err := m.atomic()
+	var ok bool
+	if err == nil {
+		ok, err = ac.Expire(ctx, ns, key, expiration)
+	}
+	m.observe(ctx, ns, "expire", start, 0, 0, err)
+	return ok, err
+}
+
+func (m *MetricsCache) namespaceCacher() (NamespaceCacher, error) {
+	if nc, ok := m.Cacher.(NamespaceCacher); ok {
+		return nc, nil
+	}
+	return nil, fmt.Errorf("%w: %T does not implement NamespaceCacher", ErrUnsupported, m.Cacher)
+}
+
+func (m *MetricsCache) ClearNamespace(ctx context.Context, ns string, progress ...ProgressFunc) (int64, error) {
+	start := time.Now()
+	nc, err := m.namespaceCacher()
+	var n int64
+	if err == nil {
+		n, err = nc.ClearNamespace(ctx, ns, progress...)
+	}
+	m.observe(ctx, ns, "clear_namespace", start, 0, 0, err)
+	return n, err
+}
+
+func (m *MetricsCache) Count(ctx context.Context, ns string) (int64, error) {
+	start := time.Now()
+	nc, err := m.namespaceCacher()
+	var n int64
+	if err == nil {
+		n, err = nc.Count(ctx, ns)
+	}
+	m.observe(ctx, ns, "count", start, 0, 0, err)
+	return n, err
+}
+
+func (m *MetricsCache) IteratorMatch(ctx context.Context, ns, pattern string, fn func(ctx context.Context, key, value string) bool) error {
+	start := time.Now()
+	err := IteratorMatch(ctx, m.Cacher, ns, pattern, fn)
+	m.observe(ctx, ns, "iterator_match", start, 0, 0, err)
+	return err
+}
+
+// Stats 返回被包装 cache 的统计,未实现 StatsCacher 时返回按命名空间汇总的命中数据
+func (m *MetricsCache) Stats() CacheStats {
+	if sc, ok := m.Cacher.(StatsCacher); ok {
+		return sc.Stats()
+	}
+	var stats CacheStats
+	for _, s := range m.Snapshot() {
+		stats.Hits += s.Hits
+		stats.Misses += s.Misses
+	}
+	return stats
+}
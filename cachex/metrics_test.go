@@ -0,0 +1,109 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gopkg-dev/karma/log"
+)
+
+type slowCache struct {
+	Cacher
+	delay time.Duration
+}
+
+func (c *slowCache) Get(ctx context.Context, ns, key string) (string, bool, error) {
+	time.Sleep(c.delay)
+	return c.Cacher.Get(ctx, ns, key)
+}
+
+type recordLogger struct {
+	mu      sync.Mutex
+	records []map[string]interface{}
+}
+
+func (l *recordLogger) Log(level log.Level, keyvals ...interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	record := map[string]interface{}{"level": level}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		record[keyvals[i].(string)] = keyvals[i+1]
+	}
+	l.records = append(l.records, record)
+	return nil
+}
+
+func TestMetricsCache(t *testing.T) {
+	t.Run("batch", func(t *testing.T) {
+		testBatchCacher(t, NewMetricsCache(NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})))
+	})
+	t.Run("atomic", func(t *testing.T) {
+		testAtomicCacher(t, NewMetricsCache(NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})))
+	})
+	t.Run("namespace", func(t *testing.T) {
+		testNamespaceCacher(t, NewMetricsCache(NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})))
+	})
+}
+
+func TestMetricsCacheSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	rl := &recordLogger{}
+	inner := &slowCache{Cacher: NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute}), delay: 5 * time.Millisecond}
+	cache := NewMetricsCache(inner,
+		WithLatencyBuckets(time.Millisecond, time.Second),
+		WithSlowThreshold(time.Millisecond),
+		WithMetricsLogger(rl),
+	)
+
+	assert.Nil(cache.Set(ctx, "a", "foo", "bar"))
+	_, ok, err := cache.Get(ctx, "a", "foo")
+	assert.Nil(err)
+	assert.True(ok)
+	_, ok, err = cache.Get(ctx, "a", "none")
+	assert.Nil(err)
+	assert.False(ok)
+
+	values, err := MGet(ctx, cache, "b", "x", "y")
+	assert.Nil(err)
+	assert.Len(values, 0)
+
+	// slowCache hides the AtomicCacher of the wrapped cache
+	_, err = cache.Incr(ctx, "b", "n")
+	assert.ErrorIs(err, ErrUnsupported)
+	assert.ErrorIs(err, errors.ErrUnsupported)
+
+	snapshot := cache.Snapshot()
+	assert.Len(snapshot, 2)
+
+	a := snapshot[0]
+	assert.Equal("a", a.Namespace)
+	assert.Equal(int64(3), a.Count)
+	assert.Equal(int64(1), a.Hits)
+	assert.Equal(int64(1), a.Misses)
+	assert.Equal(0.5, a.HitRatio())
+	assert.Equal([]LatencyBucket{{Le: time.Millisecond, Count: 1}, {Le: time.Second, Count: 3}}, a.Buckets)
+
+	b := snapshot[1]
+	assert.Equal("b", b.Namespace)
+	assert.Equal(int64(2), b.Count)
+	assert.Equal(int64(2), b.Misses)
+	assert.Equal(int64(1), b.Errors)
+
+	// get and mget are slow, set and incr are not
+	assert.Len(rl.records, 3)
+	assert.Equal(log.LevelWarn, rl.records[0]["level"])
+	assert.Equal("get", rl.records[0]["op"])
+	assert.Equal("a", rl.records[0]["ns"])
+	assert.Equal("mget", rl.records[2]["op"])
+
+	cache.Reset()
+	assert.Len(cache.Snapshot(), 0)
+	assert.Nil(cache.Close(ctx))
+}
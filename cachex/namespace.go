@@ -0,0 +1,92 @@
+package cachex
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// ProgressFunc 报告批量删除进度,deleted 为已删除的 key 数量
+type ProgressFunc func(deleted int64)
+
+// NamespaceCacher 定义命名空间管理接口,内置的 cache 驱动均已实现
+type NamespaceCacher interface {
+	// ClearNamespace 删除命名空间下所有 key,返回删除数量
+	ClearNamespace(ctx context.Context, ns string, progress ...ProgressFunc) (int64, error)
+	// Count 统计命名空间下 key 的数量
+	Count(ctx context.Context, ns string) (int64, error)
+	// IteratorMatch 遍历命名空间下匹配 pattern 的 key,pattern 语法同 Redis(*, ?, [abc], \)
+	IteratorMatch(ctx context.Context, ns, pattern string, fn func(ctx context.Context, key, value string) bool) error
+}
+
+// IteratorMatch 遍历命名空间下匹配 pattern 的 key,cache 未实现 NamespaceCacher 时基于 Iterator 过滤
+func IteratorMatch(ctx context.Context, cache Cacher, ns, pattern string, fn func(ctx context.Context, key, value string) bool) error {
+	if nc, ok := cache.(NamespaceCacher); ok {
+		return nc.IteratorMatch(ctx, ns, pattern, fn)
+	}
+
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return err
+	}
+	return cache.Iterator(ctx, ns, func(ctx context.Context, key, value string) bool {
+		if !re.MatchString(key) {
+			return true
+		}
+		return fn(ctx, key, value)
+	})
+}
+
+func reportProgress(progress []ProgressFunc, deleted int64) {
+	for _, fn := range progress {
+		fn(deleted)
+	}
+}
+
+// compilePattern 将 Redis glob 模式转换为正则表达式
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			sb.WriteString("(?s:.*)")
+		case '?':
+			sb.WriteString("(?s:.)")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			} else {
+				sb.WriteString(`\\`)
+			}
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "^") {
+				class = "^" + regexp.QuoteMeta(class[1:])
+			} else {
+				class = regexp.QuoteMeta(class)
+			}
+			sb.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+// literalPrefix 返回 pattern 中第一个通配符之前的字面量前缀
+func literalPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
@@ -0,0 +1,150 @@
+package cachex
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testNamespaceCacher(t *testing.T, cache Cacher) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	nc, ok := cache.(NamespaceCacher)
+	assert.True(ok)
+
+	values := make(map[string]string)
+	for i := 0; i < 250; i++ {
+		values[fmt.Sprintf("user:%03d", i)] = "1"
+	}
+	values["order:1"] = "2"
+	values["order:2"] = "2"
+	assert.Nil(MSet(ctx, cache, "nsclear", values))
+	assert.Nil(cache.Set(ctx, "nsother", "user:001", "3"))
+
+	n, err := nc.Count(ctx, "nsclear")
+	assert.Nil(err)
+	assert.Equal(int64(252), n)
+
+	var keys []string
+	assert.Nil(nc.IteratorMatch(ctx, "nsclear", "order:*", func(ctx context.Context, key, value string) bool {
+		keys = append(keys, key)
+		assert.Equal("2", value)
+		return true
+	}))
+	sort.Strings(keys)
+	assert.Equal([]string{"order:1", "order:2"}, keys)
+
+	keys = keys[:0]
+	assert.Nil(nc.IteratorMatch(ctx, "nsclear", "user:00[1-3]", func(ctx context.Context, key, value string) bool {
+		keys = append(keys, key)
+		return true
+	}))
+	sort.Strings(keys)
+	assert.Equal([]string{"user:001", "user:002", "user:003"}, keys)
+
+	var last int64
+	n, err = nc.ClearNamespace(ctx, "nsclear", func(deleted int64) {
+		assert.True(deleted >= last)
+		last = deleted
+	})
+	assert.Nil(err)
+	assert.Equal(int64(252), n)
+	assert.Equal(int64(252), last)
+
+	n, err = nc.Count(ctx, "nsclear")
+	assert.Nil(err)
+	assert.Equal(int64(0), n)
+
+	// other namespaces are untouched
+	value, ok, err := cache.Get(ctx, "nsother", "user:001")
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal("3", value)
+
+	assert.Nil(cache.Delete(ctx, "nsother", "user:001"))
+	assert.Nil(cache.Close(ctx))
+}
+
+func TestNamespaceCacher(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testNamespaceCacher(t, NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute}))
+	})
+	t.Run("badger", func(t *testing.T) {
+		testNamespaceCacher(t, newTestBadgerCache(t, BadgerConfig{Path: t.TempDir()}))
+	})
+	t.Run("redis", func(t *testing.T) {
+		testNamespaceCacher(t, NewRedisCacheWithClient(newTestRedisClient(t)))
+	})
+	t.Run("twolevel", func(t *testing.T) {
+		testNamespaceCacher(t, NewTwoLevelCacheWithClient(newTestRedisClient(t), TwoLevelConfig{}))
+	})
+}
+
+func TestCompilePattern(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		pattern string
+		key     string
+		match   bool
+	}{
+		{"*", "anything", true},
+		{"user:*", "user:1", true},
+		{"user:*", "order:1", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "heello", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{`a\*b`, "a*b", true},
+		{`a\*b`, "axb", false},
+		{"a.b", "axb", false},
+	}
+	for _, c := range cases {
+		re, err := compilePattern(c.pattern)
+		assert.Nil(err)
+		assert.Equal(c.match, re.MatchString(c.key), c.pattern)
+	}
+
+	assert.Equal("user:", literalPrefix("user:*"))
+	assert.Equal("abc", literalPrefix("abc"))
+}
+
+func TestTwoLevelClearNamespace(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	cfg := TwoLevelConfig{L1TTL: time.Minute, Memory: MemoryConfig{CleanupInterval: time.Minute}}
+	c1 := NewTwoLevelCacheWithClient(newTestRedisClient(t), cfg)
+	c2 := NewTwoLevelCacheWithClient(newTestRedisClient(t), cfg)
+
+	for _, key := range []string{"a", "b", "c"} {
+		assert.Nil(c1.Set(ctx, "tlclear", key, key))
+		// populate the L1 of c2
+		_, ok, err := c2.Get(ctx, "tlclear", key)
+		assert.Nil(err)
+		assert.True(ok)
+	}
+
+	n, err := c1.(NamespaceCacher).ClearNamespace(ctx, "tlclear")
+	assert.Nil(err)
+	assert.Equal(int64(3), n)
+
+	// the other instance drops the whole namespace from its L1
+	assert.Eventually(func() bool {
+		for _, key := range []string{"a", "b", "c"} {
+			if ok, _ := c2.Exists(ctx, "tlclear", key); ok {
+				return false
+			}
+		}
+		return true
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Nil(c1.Close(ctx))
+	assert.Nil(c2.Close(ctx))
+}
@@ -1,9 +1,13 @@
 package cachex
 
-const defaultDelimiter = ":"
+const (
+	defaultDelimiter = ":"
+	defaultScanCount = 100
+)
 
 type options struct {
 	Delimiter string
+	ScanCount int64
 }
 
 type Option func(*options)
@@ -13,3 +17,12 @@ func WithDelimiter(delimiter string) Option {
 		o.Delimiter = delimiter
 	}
 }
+
+// WithScanCount 设置 Redis SCAN 每页返回的建议数量,默认 100
+func WithScanCount(count int64) Option {
+	return func(o *options) {
+		if count > 0 {
+			o.ScanCount = count
+		}
+	}
+}
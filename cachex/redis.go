@@ -2,30 +2,169 @@ package cachex
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+)
+
 type RedisConfig struct {
-	Addr     string
-	UserName string
-	PassWord string
-	DB       int
+	Mode       string   // standalone(默认)/sentinel/cluster
+	Addr       string   // standalone 模式地址
+	Addrs      []string // sentinel 模式为哨兵地址,cluster 模式为节点地址
+	MasterName string   // sentinel 模式主节点名称
+	UserName   string
+	PassWord   string
+	DB         int // cluster 模式忽略
+
+	SentinelUserName string
+	SentinelPassWord string
+	ReadOnly         bool // sentinel/cluster 模式下读请求发送到从节点,主从复制存在延迟
+
+	TLS RedisTLSConfig
+
+	PoolSize        int           // 连接池大小,默认每个 CPU 10 个连接
+	MinIdleConns    int           // 最小空闲连接数
+	MaxIdleConns    int           // 最大空闲连接数
+	PoolTimeout     time.Duration // 获取连接超时时间
+	ConnMaxIdleTime time.Duration // 空闲连接最长保留时间
+	ConnMaxLifetime time.Duration // 连接最长使用时间
+	MaxRetries      int           // 最大重试次数,-1 表示不重试
+	DialTimeout     time.Duration
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+}
+
+type RedisTLSConfig struct {
+	Enable             bool
+	CertFile           string // 客户端证书,双向认证时使用
+	KeyFile            string
+	CAFile             string // 服务端 CA 证书,为空时使用系统证书
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+func (c RedisTLSConfig) build() (*tls.Config, error) {
+	if !c.Enable {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if c.CAFile != "" {
+		b, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("cachex: no certificates found in %s", c.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// NewRedisClient 按 Mode 创建 standalone/sentinel/cluster 客户端
+func NewRedisClient(cfg RedisConfig) (redis.UniversalClient, error) {
+	tlsConfig, err := cfg.TLS.build()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &redis.UniversalOptions{
+		Addrs:            cfg.Addrs,
+		DB:               cfg.DB,
+		Username:         cfg.UserName,
+		Password:         cfg.PassWord,
+		SentinelUsername: cfg.SentinelUserName,
+		SentinelPassword: cfg.SentinelPassWord,
+		MaxRetries:       cfg.MaxRetries,
+		DialTimeout:      cfg.DialTimeout,
+		ReadTimeout:      cfg.ReadTimeout,
+		WriteTimeout:     cfg.WriteTimeout,
+		PoolSize:         cfg.PoolSize,
+		PoolTimeout:      cfg.PoolTimeout,
+		MinIdleConns:     cfg.MinIdleConns,
+		MaxIdleConns:     cfg.MaxIdleConns,
+		ConnMaxIdleTime:  cfg.ConnMaxIdleTime,
+		ConnMaxLifetime:  cfg.ConnMaxLifetime,
+		TLSConfig:        tlsConfig,
+		ReadOnly:         cfg.ReadOnly,
+	}
+
+	switch cfg.Mode {
+	case "", RedisModeStandalone:
+		if cfg.Addr != "" {
+			opts.Addrs = []string{cfg.Addr}
+		}
+		return redis.NewClient(opts.Simple()), nil
+	case RedisModeSentinel:
+		if cfg.MasterName == "" || len(cfg.Addrs) == 0 {
+			return nil, errors.New("cachex: sentinel mode requires MasterName and Addrs")
+		}
+		opts.MasterName = cfg.MasterName
+		if cfg.ReadOnly {
+			// 写请求发送到主节点,读请求随机发送到从节点
+			failover := opts.Failover()
+			failover.RouteRandomly = true
+			return redis.NewFailoverClusterClient(failover), nil
+		}
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case RedisModeCluster:
+		if len(cfg.Addrs) == 0 {
+			return nil, errors.New("cachex: cluster mode requires Addrs")
+		}
+		return redis.NewClusterClient(opts.Cluster()), nil
+	default:
+		return nil, fmt.Errorf("cachex: unknown redis mode %q", cfg.Mode)
+	}
 }
 
-// NewRedisCache Create redis-based cache
+// NewRedisCache Create redis-based cache,配置无效(如 sentinel 缺少 MasterName、TLS 证书无法加载)时 panic,
+// 需要处理错误时使用 NewRedisCacheE
 func NewRedisCache(cfg RedisConfig, opts ...Option) Cacher {
-	cli := redis.NewClient(&redis.Options{
-		Addr:     cfg.Addr,
-		Username: cfg.UserName,
-		Password: cfg.PassWord,
-		DB:       cfg.DB,
-	})
+	cache, err := NewRedisCacheE(cfg, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return cache
+}
 
+// NewRedisCacheE Create redis-based cache,配置无效时返回错误
+func NewRedisCacheE(cfg RedisConfig, opts ...Option) (Cacher, error) {
+	cli, err := NewRedisClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newRedisCache(cli, opts...), nil
+}
+
+// NewRedisCacheWithUniversalClient Use redis universal client create cache
+func NewRedisCacheWithUniversalClient(cli redis.UniversalClient, opts ...Option) Cacher {
 	return newRedisCache(cli, opts...)
 }
 
@@ -39,9 +178,10 @@ func NewRedisCacheWithClusterClient(cli *redis.ClusterClient, opts ...Option) Ca
 	return newRedisCache(cli, opts...)
 }
 
-func newRedisCache(cli redisClient, opts ...Option) Cacher {
+func newRedisCache(cli redisClient, opts ...Option) *redisCache {
 	defaultOpts := &options{
 		Delimiter: defaultDelimiter,
+		ScanCount: defaultScanCount,
 	}
 
 	for _, o := range opts {
@@ -57,15 +197,26 @@ func newRedisCache(cli redisClient, opts ...Option) Cacher {
 type redisClient interface {
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
 	Get(ctx context.Context, key string) *redis.StringCmd
+	GetDel(ctx context.Context, key string) *redis.StringCmd
 	Exists(ctx context.Context, keys ...string) *redis.IntCmd
 	Del(ctx context.Context, keys ...string) *redis.IntCmd
 	Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd
+	MGet(ctx context.Context, keys ...string) *redis.SliceCmd
+	Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error)
+	IncrBy(ctx context.Context, key string, value int64) *redis.IntCmd
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd
+	PTTL(ctx context.Context, key string) *redis.DurationCmd
+	PExpire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd
+	Persist(ctx context.Context, key string) *redis.BoolCmd
+	Unlink(ctx context.Context, keys ...string) *redis.IntCmd
+	redis.Scripter
 	Close() error
 }
 
 type redisCache struct {
-	opts *options
-	cli  redisClient
+	opts     *options
+	cli      redisClient
+	noGetDel atomic.Bool // 服务端不支持 GETDEL
 }
 
 func (a *redisCache) getKey(ns, key string) string {
@@ -116,56 +267,378 @@ func (a *redisCache) Delete(ctx context.Context, ns, key string) error {
 	return nil
 }
 
+// getDelScript Redis 6.2 以下不支持 GETDEL 时使用
+var getDelScript = redis.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if v then
+	redis.call('DEL', KEYS[1])
+end
+return v
+`)
+
 func (a *redisCache) GetAndDelete(ctx context.Context, ns, key string) (string, bool, error) {
-	value, ok, err := a.Get(ctx, ns, key)
-	if err != nil {
-		return "", false, err
-	} else if !ok {
-		return "", false, nil
+	k := a.getKey(ns, key)
+
+	var (
+		value string
+		err   error
+	)
+	if !a.noGetDel.Load() {
+		value, err = a.cli.GetDel(ctx, k).Result()
+		if err != nil && strings.Contains(strings.ToLower(err.Error()), "unknown command") {
+			a.noGetDel.Store(true)
+		}
+	}
+	if a.noGetDel.Load() {
+		value, err = getDelScript.Run(ctx, a.cli, []string{k}).Text()
 	}
 
-	cmd := a.cli.Del(ctx, a.getKey(ns, key))
-	if err := cmd.Err(); err != nil && !errors.Is(err, redis.Nil) {
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", false, nil
+		}
 		return "", false, err
 	}
 	return value, true, nil
 }
 
-func (a *redisCache) Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error {
-	var cursor uint64 = 0
+// isCluster 集群模式下多 key 命令可能跨 slot,需要使用 pipeline
+func (a *redisCache) isCluster() bool {
+	_, ok := a.cli.(*redis.ClusterClient)
+	return ok
+}
 
-LbLoop:
-	for {
-		cmd := a.cli.Scan(ctx, cursor, a.getKey(ns, "*"), 100)
-		if err := cmd.Err(); err != nil {
-			return err
+func (a *redisCache) MGet(ctx context.Context, ns string, keys ...string) (map[string]string, error) {
+	fullKeys := make([]string, len(keys))
+	for i, key := range keys {
+		fullKeys[i] = a.getKey(ns, key)
+	}
+	vals, err := a.mget(ctx, fullKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(vals))
+	for i, key := range keys {
+		if value, ok := vals[fullKeys[i]]; ok {
+			values[key] = value
 		}
+	}
+	return values, nil
+}
+
+// mget 批量获取完整 key 的值,结果只包含存在的 key
+func (a *redisCache) mget(ctx context.Context, keys []string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	if a.isCluster() {
+		cmds := make([]*redis.StringCmd, len(keys))
+		_, err := a.cli.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, key := range keys {
+				cmds[i] = pipe.Get(ctx, key)
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		for i, cmd := range cmds {
+			if cmd.Err() == nil {
+				values[keys[i]] = cmd.Val()
+			}
+		}
+		return values, nil
+	}
+
+	vals, err := a.cli.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, val := range vals {
+		if s, ok := val.(string); ok {
+			values[keys[i]] = s
+		}
+	}
+	return values, nil
+}
+
+// getWithTTL 在一次往返中批量获取值及剩余有效期,永不过期时为 NoExpiration,结果只包含存在的 key
+func (a *redisCache) getWithTTL(ctx context.Context, ns string, keys ...string) (map[string]string, map[string]time.Duration, error) {
+	values := make(map[string]string, len(keys))
+	ttls := make(map[string]time.Duration, len(keys))
+	if len(keys) == 0 {
+		return values, ttls, nil
+	}
+
+	gets := make([]*redis.StringCmd, len(keys))
+	pttls := make([]*redis.DurationCmd, len(keys))
+	_, err := a.cli.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			gets[i] = pipe.Get(ctx, a.getKey(ns, key))
+			pttls[i] = pipe.PTTL(ctx, a.getKey(ns, key))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, nil, err
+	}
+	for i, key := range keys {
+		if gets[i].Err() != nil {
+			continue
+		}
+		ttl := pttls[i].Val()
+		if ttl == -2 {
+			// GET 之后已过期
+			continue
+		} else if ttl == -1 {
+			ttl = NoExpiration
+		}
+		values[key] = gets[i].Val()
+		ttls[key] = ttl
+	}
+	return values, ttls, nil
+}
+
+func (a *redisCache) MSet(ctx context.Context, ns string, values map[string]string, expiration ...time.Duration) error {
+	var exp time.Duration
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
 
-		keys, c, err := cmd.Result()
+	_, err := a.cli.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, value := range values {
+			pipe.Set(ctx, a.getKey(ns, key), value, exp)
+		}
+		return nil
+	})
+	return err
+}
+
+func (a *redisCache) MDelete(ctx context.Context, ns string, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if a.isCluster() {
+		_, err := a.cli.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				pipe.Del(ctx, a.getKey(ns, key))
+			}
+			return nil
+		})
+		return err
+	}
+
+	fullKeys := make([]string, len(keys))
+	for i, key := range keys {
+		fullKeys[i] = a.getKey(ns, key)
+	}
+	return a.cli.Del(ctx, fullKeys...).Err()
+}
+
+// incrByScript key 不存在时计数并设置过期时间(毫秒)
+var incrByScript = redis.NewScript(`
+local exists = redis.call('EXISTS', KEYS[1])
+local n = redis.call('INCRBY', KEYS[1], ARGV[1])
+if exists == 0 and tonumber(ARGV[2]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return n
+`)
+
+func (a *redisCache) Incr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	return a.IncrBy(ctx, ns, key, 1, expiration...)
+}
+
+func (a *redisCache) Decr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	return a.IncrBy(ctx, ns, key, -1, expiration...)
+}
+
+func (a *redisCache) IncrBy(ctx context.Context, ns, key string, value int64, expiration ...time.Duration) (int64, error) {
+	var (
+		n   int64
+		err error
+	)
+	if len(expiration) > 0 && expiration[0] > 0 {
+		n, err = incrByScript.Run(ctx, a.cli, []string{a.getKey(ns, key)}, value, expiration[0].Milliseconds()).Int64()
+	} else {
+		n, err = a.cli.IncrBy(ctx, a.getKey(ns, key), value).Result()
+	}
+	if err != nil && strings.Contains(err.Error(), "not an integer") {
+		return 0, ErrNotInteger
+	}
+	return n, err
+}
+
+func (a *redisCache) SetNX(ctx context.Context, ns, key, value string, expiration ...time.Duration) (bool, error) {
+	var exp time.Duration
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+	return a.cli.SetNX(ctx, a.getKey(ns, key), value, exp).Result()
+}
+
+func (a *redisCache) TTL(ctx context.Context, ns, key string) (time.Duration, bool, error) {
+	ttl, err := a.cli.PTTL(ctx, a.getKey(ns, key)).Result()
+	if err != nil {
+		return 0, false, err
+	}
+	// -2: key 不存在, -1: 永不过期
+	switch ttl {
+	case -2:
+		return 0, false, nil
+	case -1:
+		return NoExpiration, true, nil
+	}
+	return ttl, true, nil
+}
+
+func (a *redisCache) Expire(ctx context.Context, ns, key string, expiration time.Duration) (bool, error) {
+	if expiration <= 0 {
+		exists, err := a.Exists(ctx, ns, key)
+		if err != nil || !exists {
+			return false, err
+		}
+		return true, a.cli.Persist(ctx, a.getKey(ns, key)).Err()
+	}
+	return a.cli.PExpire(ctx, a.getKey(ns, key), expiration).Result()
+}
+
+// scan 按 match 遍历 key,每页调用一次 fn,集群模式下遍历所有 master 节点
+func (a *redisCache) scan(ctx context.Context, match string, fn func(keys []string) error) error {
+	if cc, ok := a.cli.(*redis.ClusterClient); ok {
+		var mu sync.Mutex
+		return cc.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return scanNode(ctx, node, match, a.opts.ScanCount, func(keys []string) error {
+				mu.Lock()
+				defer mu.Unlock()
+				return fn(keys)
+			})
+		})
+	}
+	return scanNode(ctx, a.cli, match, a.opts.ScanCount, fn)
+}
+
+func scanNode(ctx context.Context, cli redisClient, match string, count int64, fn func(keys []string) error) error {
+	var cursor uint64
+	for {
+		keys, c, err := cli.Scan(ctx, cursor, match, count).Result()
 		if err != nil {
 			return err
 		}
+		if len(keys) > 0 {
+			if err := fn(keys); err != nil {
+				return err
+			}
+		}
+		if c == 0 {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		cursor = c
+	}
+}
 
-		for _, key := range keys {
-			cmd := a.cli.Get(ctx, key)
-			if err := cmd.Err(); err != nil {
-				if errors.Is(err, redis.Nil) {
-					continue
+func (a *redisCache) ClearNamespace(ctx context.Context, ns string, progress ...ProgressFunc) (int64, error) {
+	var deleted int64
+	err := a.scan(ctx, a.getKey(ns, "*"), func(keys []string) error {
+		var n int64
+		if a.isCluster() {
+			cmds, err := a.cli.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				for _, key := range keys {
+					pipe.Unlink(ctx, key)
 				}
+				return nil
+			})
+			if err != nil {
 				return err
 			}
-			if next := fn(ctx, strings.TrimPrefix(key, a.getKey(ns, "")), cmd.Val()); !next {
-				break LbLoop
+			for _, cmd := range cmds {
+				n += cmd.(*redis.IntCmd).Val()
+			}
+		} else {
+			var err error
+			if n, err = a.cli.Unlink(ctx, keys...).Result(); err != nil {
+				return err
 			}
 		}
+		deleted += n
+		reportProgress(progress, deleted)
+		return nil
+	})
+	return deleted, err
+}
 
-		if c == 0 {
-			break
+func (a *redisCache) Count(ctx context.Context, ns string) (int64, error) {
+	seen := make(map[string]struct{})
+	err := a.scan(ctx, a.getKey(ns, "*"), func(keys []string) error {
+		// SCAN 可能返回重复的 key
+		for _, key := range keys {
+			seen[key] = struct{}{}
 		}
-		cursor = c
-	}
+		return nil
+	})
+	return int64(len(seen)), err
+}
 
-	return nil
+func (a *redisCache) IteratorMatch(ctx context.Context, ns, pattern string, fn func(ctx context.Context, key, value string) bool) error {
+	return a.iterate(ctx, ns, pattern, fn)
+}
+
+func (a *redisCache) Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error {
+	return a.iterate(ctx, ns, "*", fn)
+}
+
+// errStopIteration 回调返回 false 时终止 SCAN
+var errStopIteration = errors.New("cachex: stop iteration")
+
+// iterate 遍历命名空间下匹配 pattern 的 key,每页 SCAN 结果通过一次 MGET(集群模式为 pipeline)获取值
+// SCAN 不保证顺序,同一个 key 可能返回多次,这里按已访问的 key 去重;
+// 遍历期间新增的 key 可能不会返回,已删除的 key 会被跳过
+func (a *redisCache) iterate(ctx context.Context, ns, pattern string, fn func(ctx context.Context, key, value string) bool) error {
+	prefix := a.getKey(ns, "")
+	seen := make(map[string]struct{})
+	stopped := false
+
+	err := a.scan(ctx, a.getKey(ns, pattern), func(keys []string) error {
+		if stopped {
+			return errStopIteration
+		}
+
+		page := keys[:0:0]
+		for _, key := range keys {
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			page = append(page, key)
+		}
+
+		values, err := a.mget(ctx, page)
+		if err != nil {
+			return err
+		}
+		for _, key := range page {
+			value, ok := values[key]
+			if !ok {
+				continue
+			}
+			if !fn(ctx, strings.TrimPrefix(key, prefix), value) {
+				stopped = true
+				return errStopIteration
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errStopIteration) {
+		return nil
+	}
+	return err
 }
 
 func (a *redisCache) Close(ctx context.Context) error {
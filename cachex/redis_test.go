@@ -3,8 +3,10 @@ package cachex
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"testing"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -54,3 +56,89 @@ func TestRedisCache(t *testing.T) {
 	err = cache.Close(ctx)
 	assert.Nil(err)
 }
+
+func TestRedisCacheIterator(t *testing.T) {
+	assert := assert.New(t)
+
+	cache := NewRedisCacheWithClient(newTestRedisClient(t), WithScanCount(7))
+	ctx := context.Background()
+
+	values := make(map[string]string)
+	for i := 0; i < 50; i++ {
+		values[fmt.Sprintf("k%d", i)] = fmt.Sprintf("v%d", i)
+	}
+	assert.Nil(MSet(ctx, cache, "iter", values))
+
+	seen := make(map[string]string)
+	err := cache.Iterator(ctx, "iter", func(ctx context.Context, key, value string) bool {
+		_, dup := seen[key]
+		assert.False(dup)
+		seen[key] = value
+		return true
+	})
+	assert.Nil(err)
+	assert.Equal(values, seen)
+
+	// stop early
+	n := 0
+	err = cache.Iterator(ctx, "iter", func(ctx context.Context, key, value string) bool {
+		n++
+		return n < 3
+	})
+	assert.Nil(err)
+	assert.Equal(3, n)
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = cache.Iterator(cctx, "iter", func(ctx context.Context, key, value string) bool {
+		return true
+	})
+	assert.ErrorIs(err, context.Canceled)
+
+	_, err = cache.(NamespaceCacher).ClearNamespace(ctx, "iter")
+	assert.Nil(err)
+	assert.Nil(cache.Close(ctx))
+}
+
+func TestNewRedisClient(t *testing.T) {
+	assert := assert.New(t)
+
+	cli, err := NewRedisClient(RedisConfig{Addr: "localhost:6379", PoolSize: 3})
+	assert.Nil(err)
+	assert.IsType(&redis.Client{}, cli)
+	assert.Equal("localhost:6379", cli.(*redis.Client).Options().Addr)
+	assert.Equal(3, cli.(*redis.Client).Options().PoolSize)
+	assert.Nil(cli.Close())
+
+	cli, err = NewRedisClient(RedisConfig{Mode: RedisModeSentinel, MasterName: "mymaster", Addrs: []string{"localhost:26379"}})
+	assert.Nil(err)
+	assert.IsType(&redis.Client{}, cli)
+	assert.Nil(cli.Close())
+
+	cli, err = NewRedisClient(RedisConfig{Mode: RedisModeSentinel, MasterName: "mymaster", Addrs: []string{"localhost:26379"}, ReadOnly: true})
+	assert.Nil(err)
+	assert.IsType(&redis.ClusterClient{}, cli)
+	assert.Nil(cli.Close())
+
+	cli, err = NewRedisClient(RedisConfig{Mode: RedisModeCluster, Addrs: []string{"localhost:7000", "localhost:7001"}})
+	assert.Nil(err)
+	assert.IsType(&redis.ClusterClient{}, cli)
+	assert.Nil(cli.Close())
+
+	_, err = NewRedisClient(RedisConfig{Mode: RedisModeSentinel})
+	assert.NotNil(err)
+	_, err = NewRedisClient(RedisConfig{Mode: RedisModeCluster})
+	assert.NotNil(err)
+	_, err = NewRedisClient(RedisConfig{Mode: "unknown"})
+	assert.NotNil(err)
+
+	_, err = NewRedisClient(RedisConfig{TLS: RedisTLSConfig{Enable: true, CAFile: filepath.Join(t.TempDir(), "ca.pem")}})
+	assert.NotNil(err)
+	// NewRedisCache keeps its original signature and panics on invalid config
+	_, err = NewRedisCacheE(RedisConfig{Mode: RedisModeSentinel})
+	assert.NotNil(err)
+	assert.Panics(func() { NewRedisCache(RedisConfig{Mode: RedisModeSentinel}) })
+	_, err = NewTwoLevelCacheE(TwoLevelConfig{Redis: RedisConfig{Mode: RedisModeCluster}})
+	assert.NotNil(err)
+	assert.Panics(func() { NewTwoLevelCache(TwoLevelConfig{Redis: RedisConfig{Mode: RedisModeCluster}}) })
+}
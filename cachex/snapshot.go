@@ -0,0 +1,158 @@
+package cachex
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/gopkg-dev/karma/encoding/json"
+)
+
+// SnapshotFormat 快照文件格式
+type SnapshotFormat int
+
+const (
+	SnapshotJSON SnapshotFormat = iota // 每行一个 JSON 对象
+	SnapshotGob                        // encoding/gob 流
+)
+
+// SnapshotEntry 快照中的一条缓存数据
+type SnapshotEntry struct {
+	NS       string `json:"ns"`
+	Key      string `json:"key"`
+	Value    []byte `json:"value"`               // JSON 格式下按 base64 编码,保证二进制编解码(如 BinaryCodec/GobCodec)的数据原样导入
+	ExpireAt int64  `json:"expire_at,omitempty"` // 过期时间(Unix 毫秒),0 表示永不过期
+}
+
+type snapshotOptions struct {
+	format SnapshotFormat
+	minTTL time.Duration
+}
+
+type SnapshotOption func(*snapshotOptions)
+
+// WithSnapshotFormat 设置快照格式,默认 SnapshotJSON
+func WithSnapshotFormat(format SnapshotFormat) SnapshotOption {
+	return func(o *snapshotOptions) { o.format = format }
+}
+
+// WithSnapshotMinTTL 导入时跳过剩余有效期小于 d 的数据
+func WithSnapshotMinTTL(d time.Duration) SnapshotOption {
+	return func(o *snapshotOptions) { o.minTTL = d }
+}
+
+func newSnapshotOptions(opts []SnapshotOption) *snapshotOptions {
+	o := &snapshotOptions{format: SnapshotJSON}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// ExportSnapshot 导出命名空间下的数据到 w,返回导出数量
+// cache 实现 AtomicCacher 时保留剩余有效期,否则(或 TTL 返回 ErrUnsupported)按永不过期导出
+func ExportSnapshot(ctx context.Context, cache Cacher, w io.Writer, namespaces []string, opts ...SnapshotOption) (int64, error) {
+	o := newSnapshotOptions(opts)
+
+	var encode func(v interface{}) error
+	switch o.format {
+	case SnapshotGob:
+		encode = gob.NewEncoder(w).Encode
+	default:
+		encode = json.NewEncoder(w).Encode
+	}
+
+	ac, _ := cache.(AtomicCacher)
+	var (
+		n   int64
+		err error
+	)
+	for _, ns := range namespaces {
+		iterErr := cache.Iterator(ctx, ns, func(ctx context.Context, key, value string) bool {
+			entry := SnapshotEntry{NS: ns, Key: key, Value: []byte(value)}
+			if ac != nil {
+				ttl, ok, terr := ac.TTL(ctx, ns, key)
+				if errors.Is(terr, ErrUnsupported) {
+					// 包装的 cache 不支持 TTL
+					ac = nil
+				} else if terr != nil {
+					err = terr
+					return false
+				} else if !ok {
+					// 遍历期间已过期或被删除
+					return true
+				} else if ttl != NoExpiration {
+					entry.ExpireAt = time.Now().Add(ttl).UnixMilli()
+				}
+			}
+			if err = encode(&entry); err != nil {
+				return false
+			}
+			n++
+			return ctx.Err() == nil
+		})
+		if err != nil {
+			return n, err
+		} else if iterErr != nil {
+			return n, iterErr
+		} else if err = ctx.Err(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// ImportSnapshot 从 r 导入快照数据,已过期的数据会被跳过,返回导入数量
+func ImportSnapshot(ctx context.Context, cache Cacher, r io.Reader, opts ...SnapshotOption) (int64, error) {
+	o := newSnapshotOptions(opts)
+
+	var decode func(v interface{}) error
+	switch o.format {
+	case SnapshotGob:
+		decode = gob.NewDecoder(r).Decode
+	default:
+		br := bufio.NewReader(r)
+		decode = func(v interface{}) error {
+			for {
+				line, err := br.ReadBytes('\n')
+				if len(bytes.TrimSpace(line)) > 0 {
+					return json.Unmarshal(line, v)
+				} else if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	var n int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+
+		var entry SnapshotEntry
+		if err := decode(&entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				return n, nil
+			}
+			return n, err
+		}
+
+		var expiration []time.Duration
+		if entry.ExpireAt > 0 {
+			ttl := time.Until(time.UnixMilli(entry.ExpireAt))
+			if ttl <= 0 || ttl < o.minTTL {
+				continue
+			}
+			expiration = append(expiration, ttl)
+		}
+		if err := cache.Set(ctx, entry.NS, entry.Key, string(entry.Value), expiration...); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
@@ -0,0 +1,151 @@
+package cachex
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testSnapshot(t *testing.T, from, to Cacher, format SnapshotFormat) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	for i := 0; i < 20; i++ {
+		assert.Nil(from.Set(ctx, "snap", fmt.Sprintf("k%d", i), fmt.Sprintf("v%d", i)))
+	}
+	assert.Nil(from.Set(ctx, "snap", "ttl", "1", time.Minute))
+	assert.Nil(from.Set(ctx, "other", "foo", "bar"))
+
+	var buf bytes.Buffer
+	n, err := ExportSnapshot(ctx, from, &buf, []string{"snap"}, WithSnapshotFormat(format))
+	assert.Nil(err)
+	assert.Equal(int64(21), n)
+
+	n, err = ImportSnapshot(ctx, to, &buf, WithSnapshotFormat(format))
+	assert.Nil(err)
+	assert.Equal(int64(21), n)
+
+	value, ok, err := to.Get(ctx, "snap", "k7")
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal("v7", value)
+
+	ttl, ok, err := to.(AtomicCacher).TTL(ctx, "snap", "ttl")
+	assert.Nil(err)
+	assert.True(ok)
+	assert.True(ttl > 50*time.Second && ttl <= time.Minute)
+	ttl, _, _ = to.(AtomicCacher).TTL(ctx, "snap", "k0")
+	assert.Equal(NoExpiration, ttl)
+
+	ok, err = to.Exists(ctx, "other", "foo")
+	assert.Nil(err)
+	assert.False(ok)
+
+	assert.Nil(from.Close(ctx))
+	assert.Nil(to.Close(ctx))
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		testSnapshot(t,
+			newTestBadgerCache(t, BadgerConfig{Path: t.TempDir()}),
+			NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute}),
+			SnapshotJSON,
+		)
+	})
+	t.Run("gob", func(t *testing.T) {
+		testSnapshot(t,
+			NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute}),
+			NewBoundedMemoryCache(BoundedMemoryConfig{}),
+			SnapshotGob,
+		)
+	})
+	t.Run("redis", func(t *testing.T) {
+		testSnapshot(t,
+			newTestBadgerCache(t, BadgerConfig{InMemory: true}),
+			NewRedisCacheWithClient(newTestRedisClient(t)),
+			SnapshotJSON,
+		)
+	})
+}
+
+func TestSnapshotBinaryValues(t *testing.T) {
+	type user struct {
+		Name  string
+		Score int
+	}
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	for _, format := range []SnapshotFormat{SnapshotJSON, SnapshotGob} {
+		from := NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})
+		raw := string([]byte{0x82, 0xa1, 0x4e, 0xcc, 0xc8, 0xa1, 0x53, 0xa1, 0x78, 0xff, 0x00})
+		assert.Nil(from.Set(ctx, "bin", "raw", raw))
+		assert.Nil(NewTyped[user](from, "bin", GobCodec).Set(ctx, "gob", user{Name: "foo", Score: 200}))
+
+		var buf bytes.Buffer
+		_, err := ExportSnapshot(ctx, from, &buf, []string{"bin"}, WithSnapshotFormat(format))
+		assert.Nil(err)
+
+		to := NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})
+		n, err := ImportSnapshot(ctx, to, &buf, WithSnapshotFormat(format))
+		assert.Nil(err)
+		assert.Equal(int64(2), n)
+
+		value, ok, err := to.Get(ctx, "bin", "raw")
+		assert.Nil(err)
+		assert.True(ok)
+		assert.Equal(raw, value)
+		u, ok, err := NewTyped[user](to, "bin", GobCodec).Get(ctx, "gob")
+		assert.Nil(err)
+		assert.True(ok)
+		assert.Equal(user{Name: "foo", Score: 200}, u)
+	}
+}
+
+func TestImportSnapshotSkipsExpired(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf(`{"ns":"s","key":"old","value":"MQ==","expire_at":%d}`+"\n", time.Now().Add(-time.Second).UnixMilli()))
+	buf.WriteString(fmt.Sprintf(`{"ns":"s","key":"soon","value":"MQ==","expire_at":%d}`+"\n", time.Now().Add(time.Second).UnixMilli()))
+	buf.WriteString(`{"ns":"s","key":"new","value":"MQ=="}` + "\n")
+
+	cache := NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})
+	n, err := ImportSnapshot(ctx, cache, &buf, WithSnapshotMinTTL(10*time.Second))
+	assert.Nil(err)
+	assert.Equal(int64(1), n)
+
+	ok, _ := cache.Exists(ctx, "s", "new")
+	assert.True(ok)
+	ok, _ = cache.Exists(ctx, "s", "soon")
+	assert.False(ok)
+}
+
+func TestSnapshotUnsupportedTTL(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	// MetricsCache exposes TTL but the wrapped cache does not support it
+	from := NewMetricsCache(&slowCache{Cacher: NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})})
+	assert.Nil(from.Set(ctx, "snap", "foo", "bar", time.Minute))
+
+	var buf bytes.Buffer
+	n, err := ExportSnapshot(ctx, from, &buf, []string{"snap"})
+	assert.Nil(err)
+	assert.Equal(int64(1), n)
+
+	to := NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})
+	n, err = ImportSnapshot(ctx, to, &buf)
+	assert.Nil(err)
+	assert.Equal(int64(1), n)
+	ttl, ok, err := to.(AtomicCacher).TTL(ctx, "snap", "foo")
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal(NoExpiration, ttl)
+}
@@ -0,0 +1,341 @@
+package cachex
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/gopkg-dev/karma/encoding/json"
+	"github.com/gopkg-dev/karma/log"
+	"github.com/gopkg-dev/karma/util"
+)
+
+const defaultInvalidateChannel = "cachex:invalidate"
+
+type TwoLevelConfig struct {
+	Memory  MemoryConfig
+	Redis   RedisConfig
+	L1TTL   time.Duration // 本地缓存最长有效期,默认 1 分钟
+	L2TTL   time.Duration // 未指定过期时间时 Redis 的有效期,0 表示永不过期
+	Channel string        // 失效通知频道,默认 cachex:invalidate
+}
+
+// NewTwoLevelCache Create memory(L1) + redis(L2) cache,Redis 配置无效时 panic,需要处理错误时使用 NewTwoLevelCacheE
+func NewTwoLevelCache(cfg TwoLevelConfig, opts ...Option) Cacher {
+	cache, err := NewTwoLevelCacheE(cfg, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return cache
+}
+
+// NewTwoLevelCacheE Create memory(L1) + redis(L2) cache,Redis 配置无效时返回错误
+func NewTwoLevelCacheE(cfg TwoLevelConfig, opts ...Option) (Cacher, error) {
+	cli, err := NewRedisClient(cfg.Redis)
+	if err != nil {
+		return nil, err
+	}
+	return NewTwoLevelCacheWithClient(cli, cfg, opts...), nil
+}
+
+// NewTwoLevelCacheWithClient Use redis client create memory(L1) + redis(L2) cache
+func NewTwoLevelCacheWithClient(cli redis.UniversalClient, cfg TwoLevelConfig, opts ...Option) Cacher {
+	if cfg.L1TTL <= 0 {
+		cfg.L1TTL = time.Minute
+	}
+	if cfg.Channel == "" {
+		cfg.Channel = defaultInvalidateChannel
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ps := cli.Subscribe(ctx, cfg.Channel)
+	// 等待订阅生效,避免丢失创建后立即发布的失效通知;失败时 Channel 会自动重连并重新订阅
+	if _, err := ps.Receive(ctx); err != nil {
+		log.Context(ctx).Warnf("cachex: subscribe %s: %v", cfg.Channel, err)
+	}
+
+	a := &twoLevelCache{
+		cfg:    cfg,
+		id:     util.NewXID(),
+		l1:     NewMemoryCache(cfg.Memory, opts...),
+		l2:     newRedisCache(cli, opts...),
+		cli:    cli,
+		pubsub: ps,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go a.subscribe(ctx)
+	return a
+}
+
+type twoLevelCache struct {
+	cfg    TwoLevelConfig
+	id     string // 实例标识,忽略自身发出的失效通知
+	l1     Cacher
+	l2     *redisCache
+	cli    redis.UniversalClient
+	pubsub *redis.PubSub
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// invalidation 失效通知消息
+type invalidation struct {
+	ID   string   `json:"i"`
+	NS   string   `json:"n"`
+	Keys []string `json:"k"`
+	All  bool     `json:"a,omitempty"` // 清空整个命名空间
+}
+
+func (a *twoLevelCache) subscribe(ctx context.Context) {
+	defer close(a.done)
+	ch := a.pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var inv invalidation
+			if err := json.UnmarshalString(msg.Payload, &inv); err != nil {
+				log.Context(ctx).Warnf("cachex: invalid invalidation message: %v", err)
+				continue
+			} else if inv.ID == a.id {
+				continue
+			}
+			if inv.All {
+				_, _ = a.l1.(NamespaceCacher).ClearNamespace(ctx, inv.NS)
+				continue
+			}
+			_ = MDelete(ctx, a.l1, inv.NS, inv.Keys...)
+		}
+	}
+}
+
+// publish 通知其他实例删除本地缓存
+func (a *twoLevelCache) publish(ctx context.Context, ns string, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(invalidation{ID: a.id, NS: ns, Keys: keys})
+	if err != nil {
+		return err
+	}
+	return a.cli.Publish(ctx, a.cfg.Channel, b).Err()
+}
+
+func (a *twoLevelCache) l1Expiration(expiration ...time.Duration) time.Duration {
+	if len(expiration) > 0 && expiration[0] > 0 && expiration[0] < a.cfg.L1TTL {
+		return expiration[0]
+	}
+	return a.cfg.L1TTL
+}
+
+// fillExpiration 回填本地缓存的有效期,不超过 L2 的剩余有效期
+func (a *twoLevelCache) fillExpiration(ttl time.Duration) time.Duration {
+	if ttl > 0 && ttl < a.cfg.L1TTL {
+		return ttl
+	}
+	return a.cfg.L1TTL
+}
+
+func (a *twoLevelCache) Set(ctx context.Context, ns, key, value string, expiration ...time.Duration) error {
+	exp := a.cfg.L2TTL
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+	if err := a.l2.Set(ctx, ns, key, value, exp); err != nil {
+		return err
+	}
+	if err := a.l1.Set(ctx, ns, key, value, a.l1Expiration(expiration...)); err != nil {
+		return err
+	}
+	return a.publish(ctx, ns, key)
+}
+
+func (a *twoLevelCache) Get(ctx context.Context, ns, key string) (string, bool, error) {
+	if value, ok, err := a.l1.Get(ctx, ns, key); err == nil && ok {
+		return value, true, nil
+	}
+
+	values, ttls, err := a.l2.getWithTTL(ctx, ns, key)
+	if err != nil {
+		return "", false, err
+	}
+	value, ok := values[key]
+	if !ok {
+		return "", false, nil
+	}
+	_ = a.l1.Set(ctx, ns, key, value, a.fillExpiration(ttls[key]))
+	return value, true, nil
+}
+
+func (a *twoLevelCache) GetAndDelete(ctx context.Context, ns, key string) (string, bool, error) {
+	_ = a.l1.Delete(ctx, ns, key)
+	value, ok, err := a.l2.GetAndDelete(ctx, ns, key)
+	if err != nil || !ok {
+		return value, ok, err
+	}
+	return value, true, a.publish(ctx, ns, key)
+}
+
+func (a *twoLevelCache) Exists(ctx context.Context, ns, key string) (bool, error) {
+	if ok, err := a.l1.Exists(ctx, ns, key); err == nil && ok {
+		return true, nil
+	}
+	return a.l2.Exists(ctx, ns, key)
+}
+
+func (a *twoLevelCache) Delete(ctx context.Context, ns, key string) error {
+	if err := a.l1.Delete(ctx, ns, key); err != nil {
+		return err
+	}
+	if err := a.l2.Delete(ctx, ns, key); err != nil {
+		return err
+	}
+	return a.publish(ctx, ns, key)
+}
+
+func (a *twoLevelCache) MGet(ctx context.Context, ns string, keys ...string) (map[string]string, error) {
+	values, err := MGet(ctx, a.l1, ns, keys...)
+	if err != nil {
+		return nil, err
+	} else if len(values) == len(keys) {
+		return values, nil
+	}
+
+	missing := make([]string, 0, len(keys)-len(values))
+	for _, key := range keys {
+		if _, ok := values[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	loaded, ttls, err := a.l2.getWithTTL(ctx, ns, missing...)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range loaded {
+		_ = a.l1.Set(ctx, ns, key, value, a.fillExpiration(ttls[key]))
+		values[key] = value
+	}
+	return values, nil
+}
+
+func (a *twoLevelCache) MSet(ctx context.Context, ns string, values map[string]string, expiration ...time.Duration) error {
+	exp := a.cfg.L2TTL
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+	if err := MSet(ctx, a.l2, ns, values, exp); err != nil {
+		return err
+	}
+	if err := MSet(ctx, a.l1, ns, values, a.l1Expiration(expiration...)); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	return a.publish(ctx, ns, keys...)
+}
+
+func (a *twoLevelCache) MDelete(ctx context.Context, ns string, keys ...string) error {
+	if err := MDelete(ctx, a.l1, ns, keys...); err != nil {
+		return err
+	}
+	if err := MDelete(ctx, a.l2, ns, keys...); err != nil {
+		return err
+	}
+	return a.publish(ctx, ns, keys...)
+}
+
+// evict 删除本地缓存并通知其他实例
+func (a *twoLevelCache) evict(ctx context.Context, ns, key string) error {
+	if err := a.l1.Delete(ctx, ns, key); err != nil {
+		return err
+	}
+	return a.publish(ctx, ns, key)
+}
+
+func (a *twoLevelCache) Incr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	return a.IncrBy(ctx, ns, key, 1, expiration...)
+}
+
+func (a *twoLevelCache) Decr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	return a.IncrBy(ctx, ns, key, -1, expiration...)
+}
+
+func (a *twoLevelCache) IncrBy(ctx context.Context, ns, key string, value int64, expiration ...time.Duration) (int64, error) {
+	n, err := a.l2.IncrBy(ctx, ns, key, value, expiration...)
+	if err != nil {
+		return 0, err
+	}
+	return n, a.evict(ctx, ns, key)
+}
+
+func (a *twoLevelCache) SetNX(ctx context.Context, ns, key, value string, expiration ...time.Duration) (bool, error) {
+	exp := a.cfg.L2TTL
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+	ok, err := a.l2.SetNX(ctx, ns, key, value, exp)
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, a.evict(ctx, ns, key)
+}
+
+func (a *twoLevelCache) TTL(ctx context.Context, ns, key string) (time.Duration, bool, error) {
+	return a.l2.TTL(ctx, ns, key)
+}
+
+func (a *twoLevelCache) Expire(ctx context.Context, ns, key string, expiration time.Duration) (bool, error) {
+	ok, err := a.l2.Expire(ctx, ns, key, expiration)
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, a.evict(ctx, ns, key)
+}
+
+func (a *twoLevelCache) ClearNamespace(ctx context.Context, ns string, progress ...ProgressFunc) (int64, error) {
+	n, err := a.l2.ClearNamespace(ctx, ns, progress...)
+	if err != nil {
+		return n, err
+	}
+	if _, err := a.l1.(NamespaceCacher).ClearNamespace(ctx, ns); err != nil {
+		return n, err
+	}
+
+	b, err := json.Marshal(invalidation{ID: a.id, NS: ns, All: true})
+	if err != nil {
+		return n, err
+	}
+	return n, a.cli.Publish(ctx, a.cfg.Channel, b).Err()
+}
+
+func (a *twoLevelCache) Count(ctx context.Context, ns string) (int64, error) {
+	return a.l2.Count(ctx, ns)
+}
+
+func (a *twoLevelCache) IteratorMatch(ctx context.Context, ns, pattern string, fn func(ctx context.Context, key, value string) bool) error {
+	return IteratorMatch(ctx, a.l2, ns, pattern, fn)
+}
+
+func (a *twoLevelCache) Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error {
+	return a.l2.Iterator(ctx, ns, fn)
+}
+
+func (a *twoLevelCache) Close(ctx context.Context) error {
+	a.cancel()
+	err := a.pubsub.Close()
+	<-a.done
+	_ = a.l1.Close(ctx)
+	if cerr := a.l2.Close(ctx); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,91 @@
+package cachex
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRedisClient(t *testing.T) *redis.Client {
+	cli := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 1})
+	if err := cli.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("redis is not available: %v", err)
+	}
+	return cli
+}
+
+func TestTwoLevelCache(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := TwoLevelConfig{L1TTL: time.Minute, Memory: MemoryConfig{CleanupInterval: time.Minute}}
+	c1 := NewTwoLevelCacheWithClient(newTestRedisClient(t), cfg)
+	c2 := NewTwoLevelCacheWithClient(newTestRedisClient(t), cfg)
+
+	ctx := context.Background()
+	assert.Nil(c1.Set(ctx, "tt", "foo", "bar"))
+
+	val, exists, err := c2.Get(ctx, "tt", "foo")
+	assert.Nil(err)
+	assert.True(exists)
+	assert.Equal("bar", val)
+
+	// the update on c1 evicts the stale L1 entry on c2
+	assert.Nil(c1.Set(ctx, "tt", "foo", "baz"))
+	assert.Eventually(func() bool {
+		val, _, _ := c2.Get(ctx, "tt", "foo")
+		return val == "baz"
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Nil(c1.Delete(ctx, "tt", "foo"))
+	assert.Eventually(func() bool {
+		exists, _ := c2.Exists(ctx, "tt", "foo")
+		return !exists
+	}, time.Second, 10*time.Millisecond)
+
+	// L1 is back-filled with no more than the remaining L2 TTL
+	assert.Nil(c1.Set(ctx, "tt", "short", "v", 200*time.Millisecond))
+	assert.Nil(MSet(ctx, c1, "tt", map[string]string{"batch": "v"}, 200*time.Millisecond))
+	_, exists, err = c2.Get(ctx, "tt", "short")
+	assert.Nil(err)
+	assert.True(exists)
+	values, err := MGet(ctx, c2, "tt", "batch")
+	assert.Nil(err)
+	assert.Equal(map[string]string{"batch": "v"}, values)
+	l1 := c2.(*twoLevelCache).l1.(AtomicCacher)
+	for _, key := range []string{"short", "batch"} {
+		ttl, ok, err := l1.TTL(ctx, "tt", key)
+		assert.Nil(err)
+		assert.True(ok)
+		assert.LessOrEqual(ttl, 200*time.Millisecond)
+	}
+	time.Sleep(300 * time.Millisecond)
+	_, exists, err = c2.Get(ctx, "tt", "short")
+	assert.Nil(err)
+	assert.False(exists)
+
+	assert.Nil(c1.Close(ctx))
+	assert.Nil(c2.Close(ctx))
+}
+
+func TestTwoLevelCacheSubscribed(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	cfg := TwoLevelConfig{L1TTL: time.Minute, Memory: MemoryConfig{CleanupInterval: time.Minute}}
+	c1 := NewTwoLevelCacheWithClient(newTestRedisClient(t), cfg)
+	for i := 0; i < 10; i++ {
+		// the subscription is active once the constructor returns
+		c2 := NewTwoLevelCacheWithClient(newTestRedisClient(t), cfg)
+		assert.Nil(c2.(*twoLevelCache).l1.Set(ctx, "tt", "sub", "stale"))
+		assert.Nil(c1.Delete(ctx, "tt", "sub"))
+		assert.Eventually(func() bool {
+			ok, _ := c2.(*twoLevelCache).l1.Exists(ctx, "tt", "sub")
+			return !ok
+		}, time.Second, 10*time.Millisecond)
+		assert.Nil(c2.Close(ctx))
+	}
+	assert.Nil(c1.Close(ctx))
+}
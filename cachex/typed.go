@@ -0,0 +1,77 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Typed 基于 Cacher 的泛型缓存,使用 Codec 编解码缓存值
+type Typed[T any] struct {
+	cache Cacher
+	codec Codec
+	ns    string
+}
+
+// NewTyped 创建泛型缓存,codec 为空时使用 JSONCodec
+func NewTyped[T any](cache Cacher, ns string, codec Codec) *Typed[T] {
+	if codec == nil {
+		codec = JSONCodec
+	}
+	return &Typed[T]{
+		cache: cache,
+		codec: codec,
+		ns:    ns,
+	}
+}
+
+// Get 获取缓存值,解码失败时返回 *CodecError
+func (t *Typed[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	var value T
+	s, ok, err := t.cache.Get(ctx, t.ns, key)
+	if err != nil || !ok {
+		return value, false, err
+	}
+	if err := t.codec.Unmarshal([]byte(s), &value); err != nil {
+		return value, false, &CodecError{Op: ErrDecode, NS: t.ns, Key: key, Err: err}
+	}
+	return value, true, nil
+}
+
+// Set 设置缓存值,编码失败时返回 *CodecError
+func (t *Typed[T]) Set(ctx context.Context, key string, value T, expiration ...time.Duration) error {
+	b, err := t.codec.Marshal(&value)
+	if err != nil {
+		return &CodecError{Op: ErrEncode, NS: t.ns, Key: key, Err: err}
+	}
+	return t.cache.Set(ctx, t.ns, key, string(b), expiration...)
+}
+
+// GetOrLoad 获取缓存值,不存在或无法解码时调用 loader 加载并写入缓存
+func (t *Typed[T]) GetOrLoad(ctx context.Context, key string, loader func(ctx context.Context) (T, error), expiration ...time.Duration) (T, error) {
+	value, ok, err := t.Get(ctx, key)
+	if err == nil && ok {
+		return value, nil
+	} else if err != nil && !isCodecError(err) {
+		return value, err
+	}
+
+	value, err = loader(ctx)
+	if err != nil {
+		return value, err
+	}
+	if err := t.Set(ctx, key, value, expiration...); err != nil {
+		return value, err
+	}
+	return value, nil
+}
+
+// Delete 删除缓存值
+func (t *Typed[T]) Delete(ctx context.Context, key string) error {
+	return t.cache.Delete(ctx, t.ns, key)
+}
+
+func isCodecError(err error) bool {
+	var e *CodecError
+	return errors.As(err, &e)
+}
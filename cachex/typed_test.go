@@ -0,0 +1,141 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type typedUser struct {
+	ID   string
+	Name string
+}
+
+func TestTyped(t *testing.T) {
+	assert := assert.New(t)
+
+	cache := NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})
+	ctx := context.Background()
+
+	for _, codec := range []Codec{JSONCodec, GobCodec} {
+		users := NewTyped[typedUser](cache, "user", codec)
+		err := users.Set(ctx, "1", typedUser{ID: "1", Name: "foo"})
+		assert.Nil(err)
+
+		user, ok, err := users.Get(ctx, "1")
+		assert.Nil(err)
+		assert.True(ok)
+		assert.Equal("foo", user.Name)
+
+		_, ok, err = users.Get(ctx, "2")
+		assert.Nil(err)
+		assert.False(ok)
+	}
+
+	names := NewTyped[string](cache, "name", BinaryCodec)
+	assert.Nil(names.Set(ctx, "1", "foo"))
+	name, ok, err := names.Get(ctx, "1")
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal("foo", name)
+
+	// decode failure
+	assert.Nil(cache.Set(ctx, "user", "3", "not json"))
+	users := NewTyped[typedUser](cache, "user", nil)
+	_, _, err = users.Get(ctx, "3")
+	assert.True(errors.Is(err, ErrDecode))
+
+	// GetOrLoad overwrites undecodable values and caches loaded values
+	calls := 0
+	loader := func(ctx context.Context) (typedUser, error) {
+		calls++
+		return typedUser{ID: "3", Name: "bar"}, nil
+	}
+	user, err := users.GetOrLoad(ctx, "3", loader)
+	assert.Nil(err)
+	assert.Equal("bar", user.Name)
+	user, err = users.GetOrLoad(ctx, "3", loader)
+	assert.Nil(err)
+	assert.Equal("bar", user.Name)
+	assert.Equal(1, calls)
+
+	_, err = NewTyped[func()](cache, "fn", BinaryCodec).GetOrLoad(ctx, "1", func(ctx context.Context) (func(), error) {
+		return func() {}, nil
+	})
+	assert.True(errors.Is(err, ErrEncode))
+}
+
+type binaryOrder struct {
+	ID      int64             `msgpack:"id"`
+	Price   float64           `msgpack:"price"`
+	Paid    bool              `msgpack:"paid"`
+	Tags    []string          `msgpack:"tags"`
+	Attrs   map[string]uint16 `msgpack:"attrs"`
+	Owner   *typedUser        `msgpack:"owner"`
+	Created time.Time         `msgpack:"created"`
+	Raw     []byte            `msgpack:"raw"`
+	Skip    string            `msgpack:"-"`
+}
+
+func TestBinaryCodec(t *testing.T) {
+	assert := assert.New(t)
+
+	// MessagePack wire format
+	for v, want := range map[interface{}][]byte{
+		nil:                   {0xc0},
+		true:                  {0xc3},
+		1:                     {0x01},
+		-1:                    {0xff},
+		200:                   {0xcc, 0xc8},
+		-200:                  {0xd1, 0xff, 0x38},
+		int64(1) << 40:        {0xd3, 0, 0, 1, 0, 0, 0, 0, 0},
+		1.5:                   {0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0},
+		"a":                   {0xa1, 'a'},
+		[2]int{1, 2}:          {0x92, 0x01, 0x02},
+		struct{ A int }{A: 1}: {0x81, 0xa1, 'A', 0x01},
+	} {
+		b, err := BinaryCodec.Marshal(v)
+		assert.Nil(err)
+		assert.Equal(want, b, "%v", v)
+	}
+
+	created := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	order := binaryOrder{
+		ID:      -42,
+		Price:   9.99,
+		Paid:    true,
+		Tags:    []string{"a", strings.Repeat("b", 300)},
+		Attrs:   map[string]uint16{"w": 1, "h": 65535},
+		Owner:   &typedUser{ID: "1", Name: "foo"},
+		Created: created,
+		Raw:     []byte{0, 1, 2},
+		Skip:    "ignored",
+	}
+	b, err := BinaryCodec.Marshal(order)
+	assert.Nil(err)
+	jb, err := JSONCodec.Marshal(order)
+	assert.Nil(err)
+	assert.Less(len(b), len(jb))
+
+	var got binaryOrder
+	assert.Nil(BinaryCodec.Unmarshal(b, &got))
+	order.Skip = ""
+	assert.True(created.Equal(got.Created))
+	got.Created = order.Created
+	assert.Equal(order, got)
+
+	assert.NotNil(BinaryCodec.Unmarshal([]byte{0xa2, 'a'}, new(string)))
+
+	cache := NewMemoryCache(MemoryConfig{CleanupInterval: time.Minute})
+	ctx := context.Background()
+	counts := NewTyped[map[int]int](cache, "count", BinaryCodec)
+	assert.Nil(counts.Set(ctx, "1", map[int]int{1: 10, -2: 20}))
+	m, ok, err := counts.Get(ctx, "1")
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal(map[int]int{1: 10, -2: 20}, m)
+}
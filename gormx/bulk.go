@@ -0,0 +1,78 @@
+package gormx
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultBatchSize 批量操作默认批次大小
+const DefaultBatchSize = 500
+
+// UpsertOptions 批量插入或更新参数
+type UpsertOptions struct {
+	BatchSize       int      // 批次大小,默认 DefaultBatchSize
+	ConflictColumns []string // 冲突判断字段(唯一索引),为空时使用主键,MySQL 忽略此项
+	UpdateColumns   []string // 冲突时更新的字段,为空时更新全部字段
+	DoNothing       bool     // 冲突时忽略
+}
+
+// BatchInsert 分批插入,ctx 中存在事务时加入该事务
+func BatchInsert(ctx context.Context, db *gorm.DB, values interface{}, batchSize int) error {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return GetDB(ctx, db).CreateInBatches(values, batchSize).Error
+}
+
+// BatchUpsert 分批插入,冲突时更新指定字段
+// postgres/sqlite3 生成 ON CONFLICT,mysql 生成 ON DUPLICATE KEY UPDATE
+func BatchUpsert(ctx context.Context, db *gorm.DB, values interface{}, opts UpsertOptions) error {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	db = GetDB(ctx, db)
+	onConflict := clause.OnConflict{
+		DoNothing: opts.DoNothing,
+	}
+	for _, col := range opts.ConflictColumns {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: col})
+	}
+
+	if !opts.DoNothing {
+		if len(opts.UpdateColumns) == 0 {
+			onConflict.UpdateAll = true
+		} else {
+			onConflict.DoUpdates = clause.AssignmentColumns(opts.UpdateColumns)
+			// ON CONFLICT DO UPDATE 需要指定冲突字段
+			if len(onConflict.Columns) == 0 && db.Dialector.Name() != "mysql" {
+				stmt := &gorm.Statement{DB: db}
+				if err := stmt.Parse(values); err != nil {
+					return err
+				}
+				for _, name := range stmt.Schema.PrimaryFieldDBNames {
+					onConflict.Columns = append(onConflict.Columns, clause.Column{Name: name})
+				}
+			}
+		}
+	}
+
+	return db.Clauses(onConflict).CreateInBatches(values, batchSize).Error
+}
+
+// FindInBatches 按主键顺序分批查询,每批结果写入 dest 后调用 fn
+// 自动加入 context 中的事务,查询条件通过 scopes 传入,ctx 取消时停止迭代
+func FindInBatches(ctx context.Context, db *gorm.DB, dest interface{}, batchSize int, fn func(ctx context.Context, batch int) error, scopes ...func(*gorm.DB) *gorm.DB) error {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return GetDB(ctx, db).Scopes(scopes...).FindInBatches(dest, batchSize, func(tx *gorm.DB, batch int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(ctx, batch)
+	}).Error
+}
@@ -0,0 +1,89 @@
+package gormx
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type bulkItem struct {
+	ID    int    `gorm:"primaryKey"`
+	Code  string `gorm:"size:32;uniqueIndex"`
+	Name  string `gorm:"size:32"`
+	Count int
+}
+
+func TestBulk(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := New(Config{
+		DBType: "sqlite3",
+		DSN:    filepath.Join(t.TempDir(), "bulk.db"),
+	})
+	assert.Nil(err)
+	assert.Nil(AutoMigrate(db, new(bulkItem)))
+
+	ctx := context.Background()
+	items := make([]*bulkItem, 0, 25)
+	for i := 1; i <= 25; i++ {
+		items = append(items, &bulkItem{ID: i, Code: fmt.Sprintf("c%d", i), Name: "foo", Count: i})
+	}
+	err = ExecTrans(ctx, db, func(ctx context.Context) error {
+		return BatchInsert(ctx, db, items, 10)
+	})
+	assert.Nil(err)
+
+	updates := []*bulkItem{
+		{ID: 1, Code: "c1", Name: "bar", Count: 100},
+		{ID: 26, Code: "c26", Name: "bar", Count: 26},
+	}
+	err = BatchUpsert(ctx, db, updates, UpsertOptions{UpdateColumns: []string{"name"}})
+	assert.Nil(err)
+
+	err = BatchUpsert(ctx, db, []*bulkItem{{ID: 27, Code: "c2", Name: "baz"}}, UpsertOptions{
+		ConflictColumns: []string{"code"},
+		DoNothing:       true,
+	})
+	assert.Nil(err)
+
+	var item bulkItem
+	assert.Nil(db.First(&item, 1).Error)
+	assert.Equal("bar", item.Name)
+	assert.Equal(1, item.Count)
+
+	var total int
+	var batch []*bulkItem
+	err = FindInBatches(ctx, db, &batch, 10, func(ctx context.Context, n int) error {
+		total += len(batch)
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(26, total)
+
+	// joins the transaction carried in ctx and sees its uncommitted writes
+	err = ExecTrans(ctx, db, func(ctx context.Context) error {
+		if err := BatchInsert(ctx, db, []*bulkItem{{ID: 30, Code: "c30", Name: "tx"}}, 10); err != nil {
+			return err
+		}
+		total = 0
+		return FindInBatches(ctx, db, &batch, 10, func(ctx context.Context, n int) error {
+			total += len(batch)
+			return nil
+		}, func(db *gorm.DB) *gorm.DB {
+			return db.Where("name = ?", "tx")
+		})
+	})
+	assert.Nil(err)
+	assert.Equal(1, total)
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = FindInBatches(cctx, db, &batch, 10, func(ctx context.Context, n int) error {
+		return nil
+	})
+	assert.ErrorIs(err, context.Canceled)
+}
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	sdmysql "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
@@ -16,6 +18,8 @@ import (
 	"gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
 	"gorm.io/plugin/dbresolver"
+
+	"github.com/gopkg-dev/karma/log"
 )
 
 type ResolverConfig struct {
@@ -38,17 +42,40 @@ type Config struct {
 	TablePrefix                              string           // 表名前缀
 	DisableForeignKeyConstraintWhenMigrating bool             // 迁移时禁用外键约束
 	Resolver                                 []ResolverConfig //
+	SlowThreshold                            int              // 慢查询阈值(毫秒),大于0时记录慢查询
+	ParameterizedQueries                     bool             // 日志中隐藏SQL参数
+	Logger                                   log.Logger       // 日志记录器,为空时使用全局日志
+	EnableMetrics                            bool             // 是否采集连接池及查询指标,通过 GetMetrics 获取
+	AutoCreateDatabase                       *bool            // 数据库不存在时自动创建(mysql/postgres),为空时仅 mysql 自动创建;Resolver 的 Sources 仅在显式设置为 true 时自动创建
+	DatabaseEncoding                         string           // 自动创建数据库的字符集,mysql 默认 utf8mb4,postgres 默认 UTF8
+	DatabaseOwner                            string           // 自动创建数据库的所有者(postgres),默认为连接用户
+}
+
+// autoCreateDatabase 是否自动创建数据库,未设置时保持 mysql 自动创建的默认行为
+func (c Config) autoCreateDatabase(dbType string) bool {
+	if c.AutoCreateDatabase != nil {
+		return *c.AutoCreateDatabase
+	}
+	return strings.ToLower(dbType) == "mysql"
+}
+
+// autoCreateResolverDatabase 是否自动创建 Resolver 的 Sources 数据库,需显式开启
+func (c Config) autoCreateResolverDatabase() bool {
+	return c.AutoCreateDatabase != nil && *c.AutoCreateDatabase
 }
 
 // New 创建DB实例
 func New(cfg Config) (*gorm.DB, error) {
 	var dialector gorm.Dialector
 
-	switch strings.ToLower(cfg.DBType) {
-	case "mysql":
-		if err := createDatabaseWithMySQL(cfg.DSN); err != nil {
+	if cfg.autoCreateDatabase(cfg.DBType) {
+		if err := createDatabase(cfg, cfg.DBType, cfg.DSN); err != nil {
 			return nil, err
 		}
+	}
+
+	switch strings.ToLower(cfg.DBType) {
+	case "mysql":
 		dialector = mysql.Open(cfg.DSN)
 	case "postgres":
 		dialector = postgres.Open(cfg.DSN)
@@ -59,28 +86,45 @@ func New(cfg Config) (*gorm.DB, error) {
 		return nil, fmt.Errorf("unsupported database type: %s", cfg.DBType)
 	}
 
+	l := cfg.Logger
+	if l == nil {
+		l = log.GetLogger()
+	}
+	loggerCfg := LoggerConfig{
+		LogLevel:                  logger.Silent,
+		SlowThreshold:             time.Duration(cfg.SlowThreshold) * time.Millisecond,
+		IgnoreRecordNotFoundError: true,
+		ParameterizedQueries:      cfg.ParameterizedQueries,
+	}
+	if cfg.Debug {
+		loggerCfg.LogLevel = logger.Info
+	} else if cfg.SlowThreshold > 0 {
+		loggerCfg.LogLevel = logger.Warn
+	}
+
 	config := &gorm.Config{
 		NamingStrategy: schema.NamingStrategy{
 			TablePrefix:   cfg.TablePrefix,
 			SingularTable: true,
 		},
 		PrepareStmt:                              cfg.PrepareStmt,
-		Logger:                                   logger.Discard,
+		Logger:                                   NewLogger(l, loggerCfg),
 		DisableForeignKeyConstraintWhenMigrating: cfg.DisableForeignKeyConstraintWhenMigrating,
 	}
 
-	if cfg.Debug {
-		config.Logger = logger.Default
-	}
-
 	db, err := gorm.Open(dialector, config)
 	if err != nil {
 		return nil, err
 	}
 
+	var metrics *Metrics
+	if cfg.EnableMetrics {
+		metrics = NewMetrics()
+	}
+
 	if len(cfg.Resolver) > 0 {
 		resolver := &dbresolver.DBResolver{}
-		for _, r := range cfg.Resolver {
+		for i, r := range cfg.Resolver {
 			resolverCfg := dbresolver.Config{}
 			var open func(dsn string) gorm.Dialector
 			dbType := strings.ToLower(r.DBType)
@@ -95,17 +139,30 @@ func New(cfg Config) (*gorm.DB, error) {
 				continue
 			}
 
-			for _, replica := range r.Replicas {
+			for j, replica := range r.Replicas {
 				if dbType == "sqlite3" {
-					_ = os.MkdirAll(filepath.Dir(cfg.DSN), os.ModePerm)
+					_ = os.MkdirAll(filepath.Dir(replica), os.ModePerm)
+				}
+				d := open(replica)
+				if metrics != nil {
+					d = metrics.dialector(fmt.Sprintf("resolver.%d.replica.%d", i, j), d)
 				}
-				resolverCfg.Replicas = append(resolverCfg.Replicas, open(replica))
+				resolverCfg.Replicas = append(resolverCfg.Replicas, d)
 			}
-			for _, source := range r.Sources {
+			for j, source := range r.Sources {
+				if cfg.autoCreateResolverDatabase() {
+					if err = createDatabase(cfg, dbType, source); err != nil {
+						return nil, err
+					}
+				}
 				if dbType == "sqlite3" {
-					_ = os.MkdirAll(filepath.Dir(cfg.DSN), os.ModePerm)
+					_ = os.MkdirAll(filepath.Dir(source), os.ModePerm)
+				}
+				d := open(source)
+				if metrics != nil {
+					d = metrics.dialector(fmt.Sprintf("resolver.%d.source.%d", i, j), d)
 				}
-				resolverCfg.Sources = append(resolverCfg.Sources, open(source))
+				resolverCfg.Sources = append(resolverCfg.Sources, d)
 			}
 			tables := stringSliceToInterfaceSlice(r.Tables)
 			resolver.Register(resolverCfg, tables...)
@@ -122,6 +179,12 @@ func New(cfg Config) (*gorm.DB, error) {
 		}
 	}
 
+	if metrics != nil {
+		if err = db.Use(metrics); err != nil {
+			return nil, err
+		}
+	}
+
 	if cfg.Debug {
 		db = db.Debug()
 	}
@@ -155,19 +218,85 @@ func AutoMigrate(db *gorm.DB, dst ...interface{}) error {
 	return db.AutoMigrate(dst...)
 }
 
-func createDatabaseWithMySQL(dsn string) error {
-	cfg, err := sdmysql.ParseDSN(dsn)
+// createDatabase 数据库不存在时创建
+func createDatabase(cfg Config, dbType, dsn string) error {
+	switch strings.ToLower(dbType) {
+	case "mysql":
+		return createDatabaseWithMySQL(cfg, dsn)
+	case "postgres":
+		return createDatabaseWithPostgres(cfg, dsn)
+	}
+	return nil
+}
+
+func createDatabaseWithMySQL(cfg Config, dsn string) error {
+	dsnCfg, err := sdmysql.ParseDSN(dsn)
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s)/", dsnCfg.User, dsnCfg.Passwd, dsnCfg.Addr))
 	if err != nil {
 		return err
 	}
+	defer db.Close()
+
+	_, err = db.Exec(mysqlCreateDatabaseSQL(dsnCfg.DBName, cfg.DatabaseEncoding))
+	return err
+}
+
+func mysqlCreateDatabaseSQL(dbName, encoding string) string {
+	if encoding == "" {
+		encoding = "utf8mb4"
+	}
+	return fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s` DEFAULT CHARACTER SET = `%s`;", dbName, encoding)
+}
+
+// parsePostgresDSN 解析 DSN,返回连接 postgres 维护库的配置及目标数据库名
+func parsePostgresDSN(dsn string) (*pgx.ConnConfig, string, error) {
+	connCfg, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, "", err
+	}
+	dbName := connCfg.Database
+	connCfg.Database = "postgres"
+	return connCfg, dbName, nil
+}
+
+func postgresCreateDatabaseSQL(dbName, encoding, owner string) string {
+	if encoding == "" {
+		encoding = "UTF8"
+	}
+	query := fmt.Sprintf("CREATE DATABASE %s ENCODING %s", pgx.Identifier{dbName}.Sanitize(), quoteLiteral(encoding))
+	if owner != "" {
+		query += " OWNER " + pgx.Identifier{owner}.Sanitize()
+	}
+	return query
+}
 
-	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s)/", cfg.User, cfg.Passwd, cfg.Addr))
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func createDatabaseWithPostgres(cfg Config, dsn string) error {
+	connCfg, dbName, err := parsePostgresDSN(dsn)
 	if err != nil {
 		return err
 	}
+	if dbName == "" || dbName == "postgres" {
+		return nil
+	}
+
+	// 连接 postgres 维护库创建目标数据库
+	db := stdlib.OpenDB(*connCfg)
 	defer db.Close()
 
-	query := fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s` DEFAULT CHARACTER SET = `utf8mb4`;", cfg.DBName)
-	_, err = db.Exec(query)
+	var exists bool
+	err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", dbName).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = db.Exec(postgresCreateDatabaseSQL(dbName, cfg.DatabaseEncoding, cfg.DatabaseOwner))
 	return err
 }
@@ -0,0 +1,129 @@
+package gormx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/logger"
+
+	"github.com/gopkg-dev/karma/log"
+)
+
+var loggerSourceFile string
+
+func init() {
+	_, loggerSourceFile, _, _ = runtime.Caller(0)
+}
+
+// LoggerConfig GORM日志配置
+type LoggerConfig struct {
+	LogLevel                  logger.LogLevel // 日志级别
+	SlowThreshold             time.Duration   // 慢查询阈值,0 表示不记录慢查询
+	IgnoreRecordNotFoundError bool            // 忽略记录不存在错误
+	ParameterizedQueries      bool            // 隐藏SQL参数,仅记录占位符
+	ContextFields             []interface{}   // 附加字段,值为 log.Valuer 时从查询的 context 中取值(如 trace_id/request_id)
+}
+
+// NewLogger 创建通过 karma/log 输出的GORM日志记录器
+func NewLogger(l log.Logger, cfg LoggerConfig) logger.Interface {
+	if len(cfg.ContextFields) > 0 {
+		l = log.With(l, cfg.ContextFields...)
+	}
+	return &gormLogger{
+		logger: l,
+		cfg:    cfg,
+	}
+}
+
+type gormLogger struct {
+	logger log.Logger
+	cfg    LoggerConfig
+}
+
+func (a *gormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	l := *a
+	l.cfg.LogLevel = level
+	return &l
+}
+
+func (a *gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if a.cfg.LogLevel >= logger.Info {
+		a.log(ctx, log.LevelInfo, msg, data...)
+	}
+}
+
+func (a *gormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if a.cfg.LogLevel >= logger.Warn {
+		a.log(ctx, log.LevelWarn, msg, data...)
+	}
+}
+
+func (a *gormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if a.cfg.LogLevel >= logger.Error {
+		a.log(ctx, log.LevelError, msg, data...)
+	}
+}
+
+func (a *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if a.cfg.LogLevel <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && a.cfg.LogLevel >= logger.Error &&
+		(!errors.Is(err, logger.ErrRecordNotFound) || !a.cfg.IgnoreRecordNotFoundError):
+		a.trace(ctx, log.LevelError, elapsed, fc, "error", err.Error())
+	case a.cfg.SlowThreshold > 0 && elapsed > a.cfg.SlowThreshold && a.cfg.LogLevel >= logger.Warn:
+		a.trace(ctx, log.LevelWarn, elapsed, fc, log.DefaultMessageKey, fmt.Sprintf("SLOW SQL >= %v", a.cfg.SlowThreshold))
+	case a.cfg.LogLevel >= logger.Info:
+		a.trace(ctx, log.LevelInfo, elapsed, fc)
+	}
+}
+
+// ParamsFilter 开启 ParameterizedQueries 时丢弃SQL参数
+func (a *gormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if a.cfg.ParameterizedQueries {
+		return sql, nil
+	}
+	return sql, params
+}
+
+func (a *gormLogger) log(ctx context.Context, level log.Level, msg string, data ...interface{}) {
+	_ = log.WithContext(ctx, a.logger).Log(level,
+		log.DefaultMessageKey, fmt.Sprintf(msg, data...),
+		"caller", fileWithLineNum(),
+	)
+}
+
+func (a *gormLogger) trace(ctx context.Context, level log.Level, elapsed time.Duration, fc func() (string, int64), keyvals ...interface{}) {
+	sql, rows := fc()
+	keyvals = append(keyvals,
+		"sql", sql,
+		"rows", rows,
+		"elapsed", float64(elapsed.Nanoseconds())/1e6,
+		"caller", fileWithLineNum(),
+	)
+	_ = log.WithContext(ctx, a.logger).Log(level, keyvals...)
+}
+
+// fileWithLineNum 返回调用GORM的业务代码位置,跳过 gorm 及本文件的调用栈
+func fileWithLineNum() string {
+	pcs := [16]uintptr{}
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if frame.File != loggerSourceFile && !strings.HasPrefix(frame.Function, "gorm.io/") {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package gormx
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm/logger"
+
+	"github.com/gopkg-dev/karma/log"
+)
+
+type traceIDKey struct{}
+
+type recordLogger struct {
+	mu      sync.Mutex
+	records []map[string]interface{}
+}
+
+func (l *recordLogger) Log(level log.Level, keyvals ...interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	record := map[string]interface{}{"level": level}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		record[keyvals[i].(string)] = keyvals[i+1]
+	}
+	l.records = append(l.records, record)
+	return nil
+}
+
+func TestLogger(t *testing.T) {
+	assert := assert.New(t)
+
+	rl := &recordLogger{}
+	db, err := New(Config{
+		DBType:               "sqlite3",
+		DSN:                  filepath.Join(t.TempDir(), "logger.db"),
+		ParameterizedQueries: true,
+		Logger:               rl,
+	})
+	assert.Nil(err)
+	assert.Nil(AutoMigrate(db, new(OutboxMessage)))
+
+	db.Logger = NewLogger(rl, LoggerConfig{
+		LogLevel:             logger.Warn,
+		SlowThreshold:        time.Nanosecond,
+		ParameterizedQueries: true,
+		ContextFields: []interface{}{"trace_id", log.Valuer(func(ctx context.Context) interface{} {
+			return ctx.Value(traceIDKey{})
+		})},
+	})
+
+	ctx := context.WithValue(context.Background(), traceIDKey{}, "abc")
+	var msgs []OutboxMessage
+	assert.Nil(db.WithContext(ctx).Where("topic = ?", "secret").Find(&msgs).Error)
+
+	assert.Len(rl.records, 1)
+	record := rl.records[0]
+	assert.Equal(log.LevelWarn, record["level"])
+	assert.Equal("abc", record["trace_id"])
+	assert.Equal(int64(0), record["rows"])
+	assert.Contains(record["sql"], "topic = ?")
+	assert.NotContains(record["sql"], "secret")
+	assert.Contains(record["caller"], "logger_test.go")
+	assert.Contains(record["msg"], "SLOW SQL")
+}
@@ -0,0 +1,217 @@
+package gormx
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/gopkg-dev/karma/util"
+)
+
+const (
+	metricsPluginName = "gormx:metrics"
+	metricsStartKey   = "gormx:metrics_start"
+)
+
+// DefaultLatencyBuckets 查询耗时直方图默认分桶
+var DefaultLatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// PoolStats 连接池统计
+type PoolStats struct {
+	Name string `json:"name"` // primary 或 resolver.<i>.source.<j>/resolver.<i>.replica.<j>
+	sql.DBStats
+}
+
+// LatencyBucket 直方图分桶,Count 为耗时小于等于 Le 的累计次数
+type LatencyBucket = util.LatencyBucket
+
+// QueryStats 按数据表和操作类型汇总的查询统计
+type QueryStats struct {
+	Table     string `json:"table"`
+	Operation string `json:"operation"` // create/query/update/delete/row/raw
+	Errors    int64  `json:"errors"`
+	util.Histogram
+}
+
+// MetricsSnapshot 指标快照
+type MetricsSnapshot struct {
+	Pools   []PoolStats  `json:"pools"`
+	Queries []QueryStats `json:"queries"`
+}
+
+type namedPool struct {
+	name string
+	pool *sql.DB
+}
+
+type queryKey struct {
+	table     string
+	operation string
+}
+
+// Metrics 采集连接池及查询指标的GORM插件
+type Metrics struct {
+	mu      sync.Mutex
+	buckets []time.Duration
+	pools   []namedPool
+	queries map[queryKey]*QueryStats
+}
+
+// NewMetrics 创建指标插件,buckets 为空时使用 DefaultLatencyBuckets
+func NewMetrics(buckets ...time.Duration) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	return &Metrics{
+		buckets: util.SortBuckets(buckets),
+		queries: make(map[queryKey]*QueryStats),
+	}
+}
+
+// GetMetrics 获取 DB 上注册的指标插件
+func GetMetrics(db *gorm.DB) (*Metrics, bool) {
+	p, ok := db.Config.Plugins[metricsPluginName]
+	if !ok {
+		return nil, false
+	}
+	m, ok := p.(*Metrics)
+	return m, ok
+}
+
+func (m *Metrics) Name() string {
+	return metricsPluginName
+}
+
+func (m *Metrics) Initialize(db *gorm.DB) error {
+	if sqlDB, err := db.DB(); err == nil {
+		m.mu.Lock()
+		m.pools = append([]namedPool{{name: "primary", pool: sqlDB}}, m.pools...)
+		m.mu.Unlock()
+	}
+
+	cb := db.Callback()
+	errs := []error{
+		cb.Create().Before("*").Register(metricsPluginName+":before_create", m.before),
+		cb.Create().After("*").Register(metricsPluginName+":after_create", m.after("create")),
+		cb.Query().Before("*").Register(metricsPluginName+":before_query", m.before),
+		cb.Query().After("*").Register(metricsPluginName+":after_query", m.after("query")),
+		cb.Update().Before("*").Register(metricsPluginName+":before_update", m.before),
+		cb.Update().After("*").Register(metricsPluginName+":after_update", m.after("update")),
+		cb.Delete().Before("*").Register(metricsPluginName+":before_delete", m.before),
+		cb.Delete().After("*").Register(metricsPluginName+":after_delete", m.after("delete")),
+		cb.Row().Before("*").Register(metricsPluginName+":before_row", m.before),
+		cb.Row().After("*").Register(metricsPluginName+":after_row", m.after("row")),
+		cb.Raw().Before("*").Register(metricsPluginName+":before_raw", m.before),
+		cb.Raw().After("*").Register(metricsPluginName+":after_raw", m.after("raw")),
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddPool 添加需要统计的连接池
+func (m *Metrics) AddPool(name string, pool *sql.DB) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pools = append(m.pools, namedPool{name: name, pool: pool})
+}
+
+func (m *Metrics) before(db *gorm.DB) {
+	db.InstanceSet(metricsStartKey, time.Now())
+}
+
+func (m *Metrics) after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(metricsStartKey)
+		if !ok {
+			return
+		}
+		m.observe(db.Statement.Table, operation, time.Since(v.(time.Time)), db.Error)
+	}
+}
+
+func (m *Metrics) observe(table, operation string, elapsed time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := queryKey{table: table, operation: operation}
+	stats, ok := m.queries[key]
+	if !ok {
+		stats = &QueryStats{
+			Table:     key.table,
+			Operation: key.operation,
+			Histogram: util.NewHistogram(m.buckets),
+		}
+		m.queries[key] = stats
+	}
+	stats.Observe(elapsed)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		stats.Errors++
+	}
+}
+
+// Snapshot 返回当前指标快照
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := MetricsSnapshot{
+		Pools:   make([]PoolStats, 0, len(m.pools)),
+		Queries: make([]QueryStats, 0, len(m.queries)),
+	}
+	for _, p := range m.pools {
+		snapshot.Pools = append(snapshot.Pools, PoolStats{Name: p.name, DBStats: p.pool.Stats()})
+	}
+	for _, q := range m.queries {
+		stats := *q
+		stats.Histogram = q.Histogram.Clone()
+		snapshot.Queries = append(snapshot.Queries, stats)
+	}
+	sort.Slice(snapshot.Queries, func(i, j int) bool {
+		if snapshot.Queries[i].Table != snapshot.Queries[j].Table {
+			return snapshot.Queries[i].Table < snapshot.Queries[j].Table
+		}
+		return snapshot.Queries[i].Operation < snapshot.Queries[j].Operation
+	})
+	return snapshot
+}
+
+// dialector 包装 gorm.Dialector,初始化后将连接池登记到指标中
+func (m *Metrics) dialector(name string, d gorm.Dialector) gorm.Dialector {
+	return &metricsDialector{Dialector: d, name: name, metrics: m}
+}
+
+type metricsDialector struct {
+	gorm.Dialector
+	name    string
+	metrics *Metrics
+}
+
+func (d *metricsDialector) Initialize(db *gorm.DB) error {
+	if err := d.Dialector.Initialize(db); err != nil {
+		return err
+	}
+	if sqlDB, ok := db.ConnPool.(*sql.DB); ok {
+		d.metrics.AddPool(d.name, sqlDB)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package gormx
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	db, err := New(Config{
+		DBType:        "sqlite3",
+		DSN:           filepath.Join(dir, "primary.db"),
+		EnableMetrics: true,
+		Resolver: []ResolverConfig{{
+			DBType:   "sqlite3",
+			Replicas: []string{filepath.Join(dir, "replica.db")},
+			Tables:   []string{"outbox_message"},
+		}},
+	})
+	assert.Nil(err)
+
+	ctx := context.Background()
+	outbox := &Outbox{DB: db}
+	assert.Nil(outbox.AutoMigrate(ctx))
+	assert.Nil(outbox.Add(ctx, "foo", "1", []byte("bar")))
+
+	m, ok := GetMetrics(db)
+	assert.True(ok)
+
+	snapshot := m.Snapshot()
+	assert.Len(snapshot.Pools, 2)
+	assert.Equal("primary", snapshot.Pools[0].Name)
+	assert.Equal("resolver.0.replica.0", snapshot.Pools[1].Name)
+
+	var create *QueryStats
+	for i, q := range snapshot.Queries {
+		if q.Table == "outbox_message" && q.Operation == "create" {
+			create = &snapshot.Queries[i]
+		}
+	}
+	if assert.NotNil(create) {
+		assert.Equal(int64(1), create.Count)
+		assert.Equal(int64(0), create.Errors)
+		assert.Equal(int64(1), create.Buckets[len(create.Buckets)-1].Count)
+	}
+}
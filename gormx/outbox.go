@@ -0,0 +1,293 @@
+package gormx
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/wire"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/gopkg-dev/karma/log"
+	"github.com/gopkg-dev/karma/util"
+)
+
+// OutboxSet 注入Outbox
+var OutboxSet = wire.NewSet(wire.Struct(new(Outbox), "*"))
+
+// OutboxStatus 发件箱消息状态
+type OutboxStatus string
+
+const (
+	OutboxPending   OutboxStatus = "pending"   // 等待投递
+	OutboxPublished OutboxStatus = "published" // 投递成功
+	OutboxDead      OutboxStatus = "dead"      // 超过最大重试次数,进入死信
+)
+
+const maxOutboxErrorLen = 1024
+
+// OutboxMessage 发件箱消息
+type OutboxMessage struct {
+	ID            string       `gorm:"column:id;size:20;primaryKey"` // Unique ID
+	Topic         string       `gorm:"column:topic;size:128;index"`  // 消息主题
+	Key           string       `gorm:"column:msg_key;size:128"`      // 消息键,用于分区/去重
+	Payload       []byte       `gorm:"column:payload"`               // 消息内容
+	Status        OutboxStatus `gorm:"column:status;size:16;index"`  // 投递状态
+	Attempts      int          `gorm:"column:attempts"`              // 已投递次数
+	LastError     string       `gorm:"column:last_error;size:1024"`  // 最近一次投递失败原因
+	NextAttemptAt time.Time    `gorm:"column:next_attempt_at;index"` // 下次投递时间
+	PublishedAt   *time.Time   `gorm:"column:published_at"`          // 投递成功时间
+	CreatedAt     time.Time    `gorm:"column:created_at;index"`      // Create time
+	UpdatedAt     time.Time    `gorm:"column:updated_at"`            // Update time
+}
+
+// Outbox 发件箱,事件与业务数据在同一事务内写入
+type Outbox struct {
+	DB *gorm.DB
+}
+
+// AutoMigrate 创建发件箱数据表
+func (a *Outbox) AutoMigrate(ctx context.Context) error {
+	return AutoMigrate(a.DB.WithContext(ctx), new(OutboxMessage))
+}
+
+// Add 写入一条待投递消息,ctx 中存在事务(gormx.Trans)时加入该事务
+func (a *Outbox) Add(ctx context.Context, topic, key string, payload []byte) error {
+	now := time.Now()
+	msg := &OutboxMessage{
+		ID:            util.NewXID(),
+		Topic:         topic,
+		Key:           key,
+		Payload:       payload,
+		Status:        OutboxPending,
+		NextAttemptAt: now,
+	}
+	return GetDB(ctx, a.DB).Create(msg).Error
+}
+
+// Publisher 发件箱消息投递接口
+type Publisher interface {
+	Publish(ctx context.Context, msg *OutboxMessage) error
+}
+
+// PublisherFunc 函数形式的 Publisher
+type PublisherFunc func(ctx context.Context, msg *OutboxMessage) error
+
+func (f PublisherFunc) Publish(ctx context.Context, msg *OutboxMessage) error {
+	return f(ctx, msg)
+}
+
+type relayOptions struct {
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	retention   time.Duration
+	deadLetter  func(ctx context.Context, msg *OutboxMessage)
+}
+
+type RelayOption func(*relayOptions)
+
+// WithRelayInterval 设置轮询间隔
+func WithRelayInterval(d time.Duration) RelayOption {
+	return func(o *relayOptions) { o.interval = d }
+}
+
+// WithRelayBatchSize 设置每次轮询获取的消息数量
+func WithRelayBatchSize(n int) RelayOption {
+	return func(o *relayOptions) { o.batchSize = n }
+}
+
+// WithRelayMaxAttempts 设置最大投递次数,超过后进入死信
+func WithRelayMaxAttempts(n int) RelayOption {
+	return func(o *relayOptions) { o.maxAttempts = n }
+}
+
+// WithRelayBackoff 设置重试退避的最小/最大间隔
+func WithRelayBackoff(min, max time.Duration) RelayOption {
+	return func(o *relayOptions) {
+		o.minBackoff = min
+		o.maxBackoff = max
+	}
+}
+
+// WithRelayRetention 设置投递成功消息的保留时长,0 表示不清理
+func WithRelayRetention(d time.Duration) RelayOption {
+	return func(o *relayOptions) { o.retention = d }
+}
+
+// WithRelayDeadLetter 设置消息进入死信时的回调
+func WithRelayDeadLetter(fn func(ctx context.Context, msg *OutboxMessage)) RelayOption {
+	return func(o *relayOptions) { o.deadLetter = fn }
+}
+
+// OutboxRelay 发件箱消息中继,实现 transport.Server
+type OutboxRelay struct {
+	db        *gorm.DB
+	publisher Publisher
+	opts      *relayOptions
+	running   atomic.Bool
+	stopOnce  sync.Once
+	doneOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewOutboxRelay 创建发件箱消息中继
+func NewOutboxRelay(db *gorm.DB, publisher Publisher, opts ...RelayOption) *OutboxRelay {
+	o := &relayOptions{
+		interval:    time.Second,
+		batchSize:   100,
+		maxAttempts: 10,
+		minBackoff:  time.Second,
+		maxBackoff:  10 * time.Minute,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &OutboxRelay{
+		db:        db,
+		publisher: publisher,
+		opts:      o,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start 启动轮询,直到 ctx 取消或调用 Stop
+func (r *OutboxRelay) Start(ctx context.Context) error {
+	r.running.Store(true)
+	defer r.doneOnce.Do(func() { close(r.done) })
+
+	ticker := time.NewTicker(r.opts.interval)
+	defer ticker.Stop()
+
+	for {
+		// 已调用 Stop(包括 Start 之前)时不再开始新的批次
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-r.stop:
+			return nil
+		default:
+		}
+
+		n, err := r.Relay(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Context(ctx).Errorf("outbox relay: %v", err)
+		}
+
+		// 批次已满说明可能还有积压,立即进行下一轮
+		if err == nil && n >= r.opts.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-r.stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop 停止轮询并等待当前批次完成,ctx 结束时不再等待并返回 ctx.Err()
+func (r *OutboxRelay) Stop(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	if !r.running.Load() {
+		return nil
+	}
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Relay 执行一轮投递,返回本轮处理的消息数量
+func (r *OutboxRelay) Relay(ctx context.Context) (int, error) {
+	var (
+		n    int
+		dead []*OutboxMessage
+	)
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var msgs []*OutboxMessage
+		query := tx.Where("status = ? AND next_attempt_at <= ?", OutboxPending, time.Now()).
+			Order("created_at").
+			Limit(r.opts.batchSize)
+		if supportSkipLocked(tx) {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		if err := query.Find(&msgs).Error; err != nil {
+			return err
+		}
+
+		for _, msg := range msgs {
+			if err := r.deliver(ctx, tx, msg); err != nil {
+				return err
+			}
+			if msg.Status == OutboxDead {
+				dead = append(dead, msg)
+			}
+		}
+		n = len(msgs)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// 事务提交后再回调,回滚时消息会被重新投递
+	for _, msg := range dead {
+		log.Context(ctx).Warnf("outbox message %s dead after %d attempts: %s", msg.ID, msg.Attempts, msg.LastError)
+		if r.opts.deadLetter != nil {
+			r.opts.deadLetter(ctx, msg)
+		}
+	}
+
+	if r.opts.retention > 0 {
+		err = r.db.WithContext(ctx).
+			Where("status = ? AND published_at < ?", OutboxPublished, time.Now().Add(-r.opts.retention)).
+			Delete(new(OutboxMessage)).Error
+	}
+	return n, err
+}
+
+func (r *OutboxRelay) deliver(ctx context.Context, tx *gorm.DB, msg *OutboxMessage) error {
+	now := time.Now()
+	msg.Attempts++
+
+	pubErr := r.publisher.Publish(ctx, msg)
+	if pubErr == nil {
+		msg.Status = OutboxPublished
+		msg.PublishedAt = &now
+		msg.LastError = ""
+	} else {
+		msg.LastError = pubErr.Error()
+		if len(msg.LastError) > maxOutboxErrorLen {
+			msg.LastError = msg.LastError[:maxOutboxErrorLen]
+		}
+		if msg.Attempts >= r.opts.maxAttempts {
+			msg.Status = OutboxDead
+		} else {
+			msg.NextAttemptAt = now.Add(util.Backoff(r.opts.minBackoff, r.opts.maxBackoff, msg.Attempts))
+		}
+	}
+
+	return tx.Model(msg).Select("status", "attempts", "last_error", "next_attempt_at", "published_at", "updated_at").Updates(msg).Error
+}
+
+// supportSkipLocked 判断数据库是否支持 FOR UPDATE SKIP LOCKED
+func supportSkipLocked(db *gorm.DB) bool {
+	switch db.Dialector.Name() {
+	case "mysql", "postgres":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,120 @@
+package gormx
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutbox(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := New(Config{
+		DBType: "sqlite3",
+		DSN:    filepath.Join(t.TempDir(), "outbox.db"),
+	})
+	assert.Nil(err)
+
+	ctx := context.Background()
+	outbox := &Outbox{DB: db}
+	assert.Nil(outbox.AutoMigrate(ctx))
+
+	// rollback discards the message together with the business write
+	err = ExecTrans(ctx, db, func(ctx context.Context) error {
+		if err := outbox.Add(ctx, "user.created", "1", []byte("foo")); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	assert.NotNil(err)
+
+	err = ExecTrans(ctx, db, func(ctx context.Context) error {
+		return outbox.Add(ctx, "user.created", "2", []byte("bar"))
+	})
+	assert.Nil(err)
+
+	var published []string
+	relay := NewOutboxRelay(db, PublisherFunc(func(ctx context.Context, msg *OutboxMessage) error {
+		published = append(published, string(msg.Payload))
+		return nil
+	}))
+	n, err := relay.Relay(ctx)
+	assert.Nil(err)
+	assert.Equal(1, n)
+	assert.Equal([]string{"bar"}, published)
+
+	n, err = relay.Relay(ctx)
+	assert.Nil(err)
+	assert.Equal(0, n)
+
+	// failing messages are retried with backoff and finally dead-lettered
+	assert.Nil(outbox.Add(ctx, "user.updated", "3", []byte("baz")))
+	var dead []string
+	relay = NewOutboxRelay(db, PublisherFunc(func(ctx context.Context, msg *OutboxMessage) error {
+		return errors.New("broker unavailable")
+	}),
+		WithRelayMaxAttempts(2),
+		WithRelayBackoff(time.Millisecond, time.Millisecond),
+		WithRelayDeadLetter(func(ctx context.Context, msg *OutboxMessage) {
+			// invoked after the batch transaction commits
+			var stored OutboxMessage
+			assert.Nil(db.Where("id = ?", msg.ID).First(&stored).Error)
+			assert.Equal(OutboxDead, stored.Status)
+			dead = append(dead, msg.ID)
+		}),
+	)
+	for i := 0; i < 2; i++ {
+		time.Sleep(5 * time.Millisecond)
+		n, err = relay.Relay(ctx)
+		assert.Nil(err)
+		assert.Equal(1, n)
+	}
+	assert.Len(dead, 1)
+
+	var msg OutboxMessage
+	assert.Nil(db.Where("id = ?", dead[0]).First(&msg).Error)
+	assert.Equal(OutboxDead, msg.Status)
+	assert.Equal(2, msg.Attempts)
+	assert.Equal("broker unavailable", msg.LastError)
+
+	// run as transport.Server
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		assert.Nil(relay.Stop(ctx))
+	}()
+	assert.Nil(relay.Start(ctx))
+
+	// restarting a stopped relay returns immediately without relaying or panicking
+	assert.Nil(outbox.Add(ctx, "user.updated", "4", []byte("qux")))
+	calls := 0
+	relay = NewOutboxRelay(db, PublisherFunc(func(ctx context.Context, msg *OutboxMessage) error {
+		calls++
+		return nil
+	}))
+	assert.Nil(relay.Stop(ctx))
+	assert.Nil(relay.Start(ctx))
+	assert.Nil(relay.Start(ctx))
+	assert.Nil(relay.Stop(ctx))
+	assert.Equal(0, calls)
+
+	// Stop gives up waiting for a slow delivery once ctx is done
+	started, release := make(chan struct{}), make(chan struct{})
+	relay = NewOutboxRelay(db, PublisherFunc(func(ctx context.Context, msg *OutboxMessage) error {
+		close(started)
+		<-release
+		return nil
+	}))
+	stopped := make(chan error, 1)
+	go func() { stopped <- relay.Start(ctx) }()
+	<-started
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(relay.Stop(tctx), context.DeadlineExceeded)
+	close(release)
+	assert.Nil(<-stopped)
+	assert.Nil(relay.Stop(ctx))
+}
@@ -0,0 +1,93 @@
+package jwtx
+
+import (
+	"context"
+
+	jwtV4 "github.com/golang-jwt/jwt/v4"
+
+	"github.com/gopkg-dev/karma/encoding/json"
+)
+
+const tokenUseRefresh = "refresh"
+
+// Claims token 声明，自定义声明需嵌入该类型，例如：
+//
+//	type UserClaims struct {
+//		jwtx.Claims
+//		Roles    []string `json:"roles"`
+//		TenantID string   `json:"tid"`
+//	}
+type Claims struct {
+	jwtV4.RegisteredClaims
+	Family string `json:"fid,omitempty"` // token 族，同一次登录及其后轮换出的 token 共享
+	Use    string `json:"use,omitempty"` // refresh 表示 refresh token
+	// IssuedAtNano 签发时间（Unix 纳秒），iat 只精确到秒，用于区分与 RevokeAllForSubject 同一秒内先后签发的 token
+	IssuedAtNano int64 `json:"iat_ns,omitempty"`
+}
+
+func (c *Claims) jwtClaims() *Claims {
+	return c
+}
+
+// Valid 标准声明由 Auther 按 SetIssuer/SetAudience/SetLeeway 验证，此处不再重复（避免忽略时钟偏差）；
+// 自定义声明可覆盖该方法追加验证，在签名及标准声明验证通过后调用
+func (c *Claims) Valid() error {
+	return nil
+}
+
+// CustomClaims 嵌入了 Claims 的自定义声明
+type CustomClaims interface {
+	jwtV4.Claims
+	jwtClaims() *Claims
+}
+
+// ParseCustomClaims 从 access token 中解析出自定义声明
+func ParseCustomClaims[T any, PT interface {
+	*T
+	CustomClaims
+}](ctx context.Context, auth Auther, accessToken string) (*T, error) {
+	claims := PT(new(T))
+	if err := auth.ParseClaims(ctx, accessToken, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// standardClaims Claims 中定义的声明
+var standardClaims = map[string]struct{}{
+	"iss": {}, "sub": {}, "aud": {}, "exp": {}, "nbf": {}, "iat": {}, "jti": {}, "fid": {}, "use": {}, "iat_ns": {},
+}
+
+// mapClaims 保留未知的自定义声明，refresh token 轮换时原样带入新的 token
+type mapClaims struct {
+	Claims
+	extra map[string]interface{}
+}
+
+func (c *mapClaims) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(&c.Claims)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{}, len(c.extra))
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	for k, v := range c.extra {
+		m[k] = v
+	}
+	return json.Marshal(m)
+}
+
+func (c *mapClaims) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &c.Claims); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &c.extra); err != nil {
+		return err
+	}
+	for k := range standardClaims {
+		delete(c.extra, k)
+	}
+	return nil
+}
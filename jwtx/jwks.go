@@ -0,0 +1,70 @@
+package jwtx
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// JWK 公钥(RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet 公钥集合，HMAC 密钥不会公开
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func encodeBase64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// encodeCoordinate 按曲线长度补齐椭圆曲线坐标
+func encodeCoordinate(v *big.Int, size int) string {
+	return encodeBase64(v.FillBytes(make([]byte, size)))
+}
+
+// newJWK 转换公钥，不支持的密钥类型返回 false
+func newJWK(key *Key) (JWK, bool) {
+	jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+	switch pub := key.PublicKey().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeBase64(pub.N.Bytes())
+		jwk.E = encodeBase64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = encodeCoordinate(pub.X, size)
+		jwk.Y = encodeCoordinate(pub.Y, size)
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = encodeBase64(pub)
+	default:
+		return JWK{}, false
+	}
+	return jwk, true
+}
+
+// JWKSHandler 以 JWKS 格式公开验证公钥，通常挂载在 /.well-known/jwks.json
+func JWKSHandler(auth Auther) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+		return c.JSON(auth.JWKS())
+	}
+}
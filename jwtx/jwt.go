@@ -2,6 +2,9 @@ package jwtx
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"sort"
 	"time"
 
 	jwtV4 "github.com/golang-jwt/jwt/v4"
@@ -14,10 +17,23 @@ import (
 type Auther interface {
 	// GenerateToken 生成一个包含给定 subject 的 JWT（JSON Web Token）
 	GenerateToken(ctx context.Context, subject string) (TokenInfo, error)
-	// DestroyToken 使 token 失效，从 token 存储中移除
+	// GenerateTokenWithClaims 生成包含自定义声明的 token，claims 中的标准声明由该方法填充
+	GenerateTokenWithClaims(ctx context.Context, subject string, claims CustomClaims) (TokenInfo, error)
+	// DestroyToken 使 token 及其所属会话失效（退出登录）
 	DestroyToken(ctx context.Context, accessToken string) error
+	// RevokeAllForSubject 使 subject 已签发的 token 全部失效（退出所有设备），未配置 Store 时返回 ErrStoreMissing
+	RevokeAllForSubject(ctx context.Context, subject string) error
+	// ListSessions 列出 subject 当前有效的会话
+	ListSessions(ctx context.Context, subject string) ([]*Session, error)
 	// ParseSubject 从给定的 access token 中解析出 subject（或用户标识）
 	ParseSubject(ctx context.Context, accessToken string) (string, error)
+	// ParseClaims 从给定的 access token 中解析出声明到 claims
+	ParseClaims(ctx context.Context, accessToken string, claims CustomClaims) error
+	// RefreshToken 使用 refresh token 换取新的 token 对，旧的 refresh token 随即失效（轮换）；
+	// 已使用过的 refresh token 再次使用时吊销其所属的整个 token 族
+	RefreshToken(ctx context.Context, refreshToken string) (TokenInfo, error)
+	// JWKS 返回用于验证 token 的公钥集合
+	JWKS() JWKSet
 	// Release 释放 JWTAuth 实例持有的任何资源
 	Release(ctx context.Context) error
 }
@@ -25,20 +41,40 @@ type Auther interface {
 const defaultKey = "E4N6B7H9A2R5S3T1U8"
 
 var (
-	ErrMissingJwtToken        = errors.Unauthorized("JWT token is missing")
-	ErrMissingKeyFunc         = errors.Unauthorized("keyFunc is missing")
-	ErrTokenInvalid           = errors.Unauthorized("Token is invalid")
-	ErrTokenExpired           = errors.Unauthorized("JWT token has expired")
-	ErrTokenParseFail         = errors.Unauthorized("Fail to parse JWT token ")
-	ErrUnSupportSigningMethod = errors.Unauthorized("Wrong signing method")
+	ErrMissingJwtToken        = unauthorized("TokenMissing", "JWT token is missing")
+	ErrMissingKeyFunc         = unauthorized("KeyFuncMissing", "keyFunc is missing")
+	ErrTokenInvalid           = unauthorized("TokenInvalid", "Token is invalid")
+	ErrTokenExpired           = unauthorized("TokenExpired", "JWT token has expired")
+	ErrTokenNotValidYet       = unauthorized("TokenNotValidYet", "JWT token is not valid yet")
+	ErrTokenParseFail         = unauthorized("TokenParseFail", "Fail to parse JWT token ")
+	ErrTokenSignatureInvalid  = unauthorized("TokenSignatureInvalid", "JWT token signature is invalid")
+	ErrInvalidIssuer          = unauthorized("TokenIssuerInvalid", "JWT token issuer is invalid")
+	ErrInvalidAudience        = unauthorized("TokenAudienceInvalid", "JWT token audience is invalid")
+	ErrUnSupportSigningMethod = unauthorized("SigningMethodUnsupported", "Wrong signing method")
+	ErrRefreshTokenReused     = unauthorized("RefreshTokenReused", "Refresh token has been reused")
+	ErrUnknownKeyID           = unauthorized("SigningKeyUnknown", "Unknown signing key")
+	ErrSigningKeyMissing      = errors.New(http.StatusInternalServerError, "SigningKeyMissing", "No signing key configured")
+	ErrStoreMissing           = errors.New(http.StatusInternalServerError, "StoreMissing", "No token store configured")
 )
 
+// unauthorized 生成 401 错误，reason 区分具体的验证失败原因
+func unauthorized(reason, message string) *errors.Error {
+	return errors.New(http.StatusUnauthorized, reason, message)
+}
+
 type options struct {
-	signingMethod jwtV4.SigningMethod
-	signingKey    []byte
-	keyFunc       jwtV4.Keyfunc
-	expired       int
-	tokenType     string
+	signingMethod  jwtV4.SigningMethod
+	signingKey     []byte
+	key            *Key            // 当前签名密钥，nil 表示仅验证
+	keys           map[string]*Key // 按 kid 索引的验证密钥
+	err            error           // 密钥配置错误，由 New 返回
+	keyFunc        jwtV4.Keyfunc
+	expired        int
+	refreshExpired int
+	tokenType      string
+	issuer         string
+	audience       []string
+	leeway         time.Duration
 }
 
 type Option func(*options)
@@ -55,13 +91,83 @@ func SetSigningKey(key string) Option {
 	}
 }
 
+// SetKeys 设置签名密钥，current 用于签名及验证且必须持有私钥，previous 仅用于验证（密钥轮换期间仍在有效期内的 token），
+// 设置后忽略 SetSigningMethod 及 SetSigningKey
+func SetKeys(current *Key, previous ...*Key) Option {
+	return func(o *options) {
+		if current == nil || !current.CanSign() {
+			o.err = fmt.Errorf("jwtx: SetKeys requires a current key that can sign")
+			return
+		}
+		o.key = current
+		o.keys = make(map[string]*Key, len(previous)+1)
+		for _, key := range previous {
+			if key == nil {
+				o.err = fmt.Errorf("jwtx: SetKeys got a nil previous key")
+				return
+			}
+			o.keys[key.ID] = key
+		}
+		o.keys[current.ID] = current
+	}
+}
+
+// SetVerifyKeys 仅设置验证密钥，用于只持有公钥的服务，签发 token 时返回 ErrSigningKeyMissing
+func SetVerifyKeys(keys ...*Key) Option {
+	return func(o *options) {
+		if len(keys) == 0 {
+			o.err = fmt.Errorf("jwtx: SetVerifyKeys requires at least one key")
+			return
+		}
+		o.key = nil
+		o.keys = make(map[string]*Key, len(keys))
+		for _, key := range keys {
+			if key == nil {
+				o.err = fmt.Errorf("jwtx: SetVerifyKeys got a nil key")
+				return
+			}
+			o.keys[key.ID] = key
+		}
+	}
+}
+
+// SetIssuer 设置签发者，签发的 token 写入 iss，验证时要求 iss 一致
+func SetIssuer(issuer string) Option {
+	return func(o *options) {
+		o.issuer = issuer
+	}
+}
+
+// SetAudience 设置接收方，签发的 token 写入 aud，验证时要求 aud 至少包含其中之一
+func SetAudience(audience ...string) Option {
+	return func(o *options) {
+		o.audience = audience
+	}
+}
+
+// SetLeeway 设置验证 exp/nbf/iat 时允许的时钟偏差
+func SetLeeway(leeway time.Duration) Option {
+	return func(o *options) {
+		o.leeway = leeway
+	}
+}
+
 func SetExpired(expired int) Option {
 	return func(o *options) {
 		o.expired = expired
 	}
 }
 
-func New(store Store, opts ...Option) Auther {
+// SetRefreshExpired 设置 refresh token 有效期（秒），默认 0 不签发 refresh token；
+// 轮换及重复使用检测依赖 Store，未配置 Store 时签发及刷新 token 返回 ErrStoreMissing
+func SetRefreshExpired(expired int) Option {
+	return func(o *options) {
+		o.refreshExpired = expired
+	}
+}
+
+// New 创建 JWT 认证，SetKeys/SetVerifyKeys 配置无效时返回错误
+func New(store Store, opts ...Option) (Auther, error) {
 	o := options{
 		tokenType:     "Bearer",
 		expired:       7200,
@@ -71,16 +177,27 @@ func New(store Store, opts ...Option) Auther {
 	for _, opt := range opts {
 		opt(&o)
 	}
+	if o.err != nil {
+		return nil, o.err
+	}
+	if o.keys == nil {
+		o.key = NewHMACKey("", o.signingMethod, o.signingKey)
+		o.keys = map[string]*Key{"": o.key}
+	}
 	o.keyFunc = func(t *jwtV4.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwtV4.SigningMethodHMAC); !ok {
-			return nil, ErrMissingKeyFunc
+		kid, _ := t.Header["kid"].(string)
+		key, ok := o.keys[kid]
+		if !ok {
+			return nil, ErrUnknownKeyID
+		} else if t.Method.Alg() != key.Method.Alg() {
+			return nil, ErrUnSupportSigningMethod
 		}
-		return o.signingKey, nil
+		return key.verifyKey, nil
 	}
 	return &JWTAuth{
 		opts:  &o,
 		store: store,
-	}
+	}, nil
 }
 
 type JWTAuth struct {
@@ -89,36 +206,132 @@ type JWTAuth struct {
 }
 
 func (a *JWTAuth) GenerateToken(ctx context.Context, subject string) (TokenInfo, error) {
+	return a.GenerateTokenWithClaims(ctx, subject, &Claims{})
+}
+
+func (a *JWTAuth) GenerateTokenWithClaims(ctx context.Context, subject string, claims CustomClaims) (TokenInfo, error) {
+	info, err := a.generateToken(subject, uuid.NewString(), claims)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.saveSession(ctx, claims.jwtClaims(), false); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (a *JWTAuth) generateToken(subject, family string, claims CustomClaims) (TokenInfo, error) {
+	c := claims.jwtClaims()
+	c.Subject = subject
+	c.Family = family
+	if c.Issuer == "" {
+		c.Issuer = a.opts.issuer
+	}
+	if len(c.Audience) == 0 && len(a.opts.audience) > 0 {
+		c.Audience = a.opts.audience
+	}
+
 	now := time.Now()
+	info := &tokenInfo{TokenType: a.opts.tokenType}
+	if a.opts.refreshExpired > 0 {
+		if a.store == nil {
+			return nil, ErrStoreMissing
+		}
+		refreshExpiresAt := now.Add(a.refreshExpiration())
+		refreshToken, err := a.signToken(claims, now, refreshExpiresAt, tokenUseRefresh)
+		if err != nil {
+			return nil, err
+		}
+		info.RefreshToken = refreshToken
+		info.RefreshExpiresAt = refreshExpiresAt.Unix()
+	}
+
+	// 最后签发 access token，返回后 claims 与 access token 一致
 	expiresAt := now.Add(time.Duration(a.opts.expired) * time.Second)
-	token := jwtV4.NewWithClaims(a.opts.signingMethod, &jwtV4.RegisteredClaims{
-		Subject:   subject,
-		ExpiresAt: jwtV4.NewNumericDate(expiresAt),
-		NotBefore: jwtV4.NewNumericDate(now),
-		IssuedAt:  jwtV4.NewNumericDate(now),
-		ID:        uuid.NewString(),
-	})
-	jwtToken, err := token.SignedString(a.opts.signingKey)
+	jwtToken, err := a.signToken(claims, now, expiresAt, "")
 	if err != nil {
 		return nil, err
 	}
-	info := &tokenInfo{
-		ExpiresAt:   expiresAt.Unix(),
-		TokenType:   a.opts.tokenType,
-		AccessToken: jwtToken,
-	}
+	info.AccessToken = jwtToken
+	info.ExpiresAt = expiresAt.Unix()
 	return info, nil
 }
 
-func (a *JWTAuth) parseToken(jwtToken string) (*jwtV4.RegisteredClaims, error) {
-	token, err := jwtV4.ParseWithClaims(jwtToken, &jwtV4.RegisteredClaims{}, a.opts.keyFunc)
+func (a *JWTAuth) signToken(claims CustomClaims, now, expiresAt time.Time, use string) (string, error) {
+	key := a.opts.key
+	if key == nil {
+		return "", ErrSigningKeyMissing
+	}
+
+	c := claims.jwtClaims()
+	c.ExpiresAt = jwtV4.NewNumericDate(expiresAt)
+	c.NotBefore = jwtV4.NewNumericDate(now)
+	c.IssuedAt = jwtV4.NewNumericDate(now)
+	c.IssuedAtNano = now.UnixNano()
+	c.ID = uuid.NewString()
+	c.Use = use
+
+	token := jwtV4.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(key.signKey)
+}
+
+func (a *JWTAuth) refreshExpiration() time.Duration {
+	return time.Duration(a.opts.refreshExpired) * time.Second
+}
+
+// sessionExpiration 会话有效期，覆盖会话内签发的所有 token
+func (a *JWTAuth) sessionExpiration() time.Duration {
+	return max(a.refreshExpiration(), time.Duration(a.opts.expired)*time.Second)
+}
+
+// saveSession 记录会话及最近签发的 token，refreshed 表示 refresh token 轮换
+func (a *JWTAuth) saveSession(ctx context.Context, c *Claims, refreshed bool) error {
+	return a.callStore(func(store Store) error {
+		now := time.Now()
+		session := &Session{ID: c.Family, Subject: c.Subject, IssuedAt: now}
+		if refreshed {
+			sessions, err := store.ListSessions(ctx, c.Subject)
+			if err != nil {
+				return err
+			}
+			for _, s := range sessions {
+				if s.ID == c.Family {
+					session = s
+					break
+				}
+			}
+		}
+		session.TokenID = c.ID
+		session.RefreshedAt = now
+		session.ExpiresAt = now.Add(a.sessionExpiration())
+		if info, ok := FromClientInfo(ctx); ok {
+			session.UserAgent = info.UserAgent
+			session.IP = info.IP
+		}
+		return store.SaveSession(ctx, session)
+	})
+}
+
+// parser 由 validateClaims 验证标准声明，以支持时钟偏差
+var parser = jwtV4.NewParser(jwtV4.WithoutClaimsValidation())
+
+func (a *JWTAuth) parseToken(jwtToken string, claims CustomClaims) (*Claims, error) {
+	token, err := parser.ParseWithClaims(jwtToken, claims, a.opts.keyFunc)
 	if err != nil {
 		var ve *jwtV4.ValidationError
 		if errors.As(err, &ve) {
+			// keyFunc 返回的错误
+			var e *errors.Error
+			if errors.As(ve.Inner, &e) {
+				return nil, e
+			}
 			if ve.Errors&jwtV4.ValidationErrorMalformed != 0 {
 				return nil, ErrTokenInvalid
-			} else if ve.Errors&(jwtV4.ValidationErrorExpired|jwtV4.ValidationErrorNotValidYet) != 0 {
-				return nil, ErrTokenExpired
+			} else if ve.Errors&jwtV4.ValidationErrorSignatureInvalid != 0 {
+				return nil, ErrTokenSignatureInvalid
 			} else {
 				return nil, ErrTokenParseFail
 			}
@@ -126,10 +339,114 @@ func (a *JWTAuth) parseToken(jwtToken string) (*jwtV4.RegisteredClaims, error) {
 		return nil, err
 	} else if !token.Valid {
 		return nil, ErrTokenInvalid
-	} else if token.Method != a.opts.signingMethod {
-		return nil, ErrUnSupportSigningMethod
 	}
-	return token.Claims.(*jwtV4.RegisteredClaims), nil
+
+	c := claims.jwtClaims()
+	if err := a.validateClaims(c); err != nil {
+		return nil, err
+	}
+	// 解析时关闭了声明验证，显式调用以执行自定义声明的验证
+	if err := claims.Valid(); err != nil {
+		var e *errors.Error
+		if errors.As(err, &e) {
+			return nil, e
+		}
+		return nil, ErrTokenInvalid.WithMessage("%s", err)
+	}
+	return c, nil
+}
+
+func (a *JWTAuth) validateClaims(c *Claims) error {
+	now := time.Now()
+	if !c.VerifyExpiresAt(now.Add(-a.opts.leeway), false) {
+		return ErrTokenExpired
+	} else if !c.VerifyNotBefore(now.Add(a.opts.leeway), false) ||
+		!c.VerifyIssuedAt(now.Add(a.opts.leeway), false) {
+		return ErrTokenNotValidYet
+	}
+
+	if a.opts.issuer != "" && !c.VerifyIssuer(a.opts.issuer, true) {
+		return ErrInvalidIssuer
+	}
+	if len(a.opts.audience) > 0 {
+		for _, aud := range a.opts.audience {
+			if c.VerifyAudience(aud, true) {
+				return nil
+			}
+		}
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+// tokenKey 吊销的 token 在 Store 中的 key
+func tokenKey(c *Claims, jwtToken string) string {
+	if c.ID == "" {
+		return jwtToken
+	}
+	return "jti:" + c.ID
+}
+
+// familyKey 吊销的 token 族在 Store 中的 key
+func familyKey(family string) string {
+	return "family:" + family
+}
+
+// refreshKey 已使用的 refresh token 在 Store 中的 key
+func refreshKey(id string) string {
+	return "refresh:" + id
+}
+
+// checkRevoked 检查 token 本身、其所属的 token 族或 subject 是否已被吊销
+func (a *JWTAuth) checkRevoked(ctx context.Context, store Store, jwtToken string, c *Claims) error {
+	if exists, err := store.Check(ctx, tokenKey(c, jwtToken)); err != nil {
+		return err
+	} else if exists {
+		return ErrTokenInvalid
+	}
+	if c.ID != "" {
+		// 兼容旧版本以原始 token 为 key 的吊销记录，升级前签发的 token 全部过期后可移除
+		if exists, err := store.Check(ctx, jwtToken); err != nil {
+			return err
+		} else if exists {
+			return ErrTokenInvalid
+		}
+	}
+	if c.Family != "" {
+		if exists, err := store.Check(ctx, familyKey(c.Family)); err != nil {
+			return err
+		} else if exists {
+			return ErrTokenInvalid
+		}
+	}
+	if c.Subject != "" {
+		if at, ok, err := store.RevokedAt(ctx, c.Subject); err != nil {
+			return err
+		} else if ok && !issuedAt(c).After(at) {
+			return ErrTokenInvalid
+		}
+	}
+	return nil
+}
+
+// issuedAt token 的签发时间，优先使用纳秒精度的 iat_ns；
+// 缺少 iat_ns 的旧 token 只能精确到秒，与吊销同一秒内签发的视为已吊销
+func issuedAt(c *Claims) time.Time {
+	if c.IssuedAtNano > 0 {
+		return time.Unix(0, c.IssuedAtNano)
+	} else if c.IssuedAt != nil {
+		return c.IssuedAt.Time
+	}
+	return time.Time{}
+}
+
+// remaining token 仍可通过验证的时长（含 leeway），用作吊销等记录的有效期；
+// 时钟偏差内被接受的 token 已超过 exp，至少保留 1 秒，避免负数被 Store 视为不过期
+func (a *JWTAuth) remaining(c *Claims) time.Duration {
+	if c.ExpiresAt == nil {
+		return a.sessionExpiration()
+	}
+	return max(time.Until(c.ExpiresAt.Time)+a.opts.leeway, time.Second)
 }
 
 func (a *JWTAuth) callStore(fn func(Store) error) error {
@@ -140,40 +457,145 @@ func (a *JWTAuth) callStore(fn func(Store) error) error {
 }
 
 func (a *JWTAuth) DestroyToken(ctx context.Context, jwtToken string) error {
-	claims, err := a.parseToken(jwtToken)
+	claims, err := a.parseToken(jwtToken, &Claims{})
 	if err != nil {
 		return err
 	}
 
 	return a.callStore(func(store Store) error {
-		expired := claims.ExpiresAt.Sub(time.Now())
-		return store.Set(ctx, jwtToken, expired)
+		if err := store.Set(ctx, tokenKey(claims, jwtToken), a.remaining(claims)); err != nil {
+			return err
+		}
+		if claims.Family == "" {
+			return nil
+		}
+		// 同时吊销 token 族，退出登录后 refresh token 不能再换取新的 token
+		if err := store.Set(ctx, familyKey(claims.Family), a.sessionExpiration()); err != nil {
+			return err
+		}
+		return store.DeleteSession(ctx, claims.Subject, claims.Family)
+	})
+}
+
+func (a *JWTAuth) RevokeAllForSubject(ctx context.Context, subject string) error {
+	if a.store == nil {
+		// 没有 Store 无法吊销，不能让调用方误以为已退出所有设备
+		return ErrStoreMissing
+	}
+	return a.callStore(func(store Store) error {
+		sessions, err := store.ListSessions(ctx, subject)
+		if err != nil {
+			return err
+		}
+		ids := make([]string, 0, len(sessions))
+		for _, session := range sessions {
+			if err := store.Set(ctx, familyKey(session.ID), time.Until(session.ExpiresAt)); err != nil {
+				return err
+			}
+			ids = append(ids, session.ID)
+		}
+		// 会话列表之外（如并发写入丢失）的 token 按签发时间吊销
+		if err := store.RevokeSubject(ctx, subject, time.Now(), a.sessionExpiration()); err != nil {
+			return err
+		}
+		return store.DeleteSession(ctx, subject, ids...)
+	})
+}
+
+func (a *JWTAuth) ListSessions(ctx context.Context, subject string) ([]*Session, error) {
+	var sessions []*Session
+	err := a.callStore(func(store Store) error {
+		var err error
+		sessions, err = store.ListSessions(ctx, subject)
+		return err
 	})
+	return sessions, err
 }
 
 func (a *JWTAuth) ParseSubject(ctx context.Context, jwtToken string) (string, error) {
+	claims := &Claims{}
+	if err := a.ParseClaims(ctx, jwtToken, claims); err != nil {
+		return "", err
+	}
+	return claims.Subject, nil
+}
+
+func (a *JWTAuth) ParseClaims(ctx context.Context, jwtToken string, claims CustomClaims) error {
 	if jwtToken == "" {
-		return "", ErrMissingJwtToken
+		return ErrMissingJwtToken
 	}
 
-	claims, err := a.parseToken(jwtToken)
+	c, err := a.parseToken(jwtToken, claims)
 	if err != nil {
-		return "", err
+		return err
+	} else if c.Use == tokenUseRefresh {
+		return ErrTokenInvalid
+	}
+
+	return a.callStore(func(store Store) error {
+		return a.checkRevoked(ctx, store, jwtToken, c)
+	})
+}
+
+func (a *JWTAuth) RefreshToken(ctx context.Context, refreshToken string) (TokenInfo, error) {
+	if refreshToken == "" {
+		return nil, ErrMissingJwtToken
+	} else if a.store == nil {
+		return nil, ErrStoreMissing
+	}
+
+	// 保留自定义声明，轮换后的 token 原样携带
+	custom := &mapClaims{}
+	claims, err := a.parseToken(refreshToken, custom)
+	if err != nil {
+		return nil, err
+	} else if claims.Use != tokenUseRefresh || claims.Family == "" {
+		return nil, ErrTokenInvalid
 	}
 
 	err = a.callStore(func(store Store) error {
-		if exists, err := store.Check(ctx, jwtToken); err != nil {
+		if err := a.checkRevoked(ctx, store, refreshToken, claims); err != nil {
 			return err
-		} else if exists {
-			return ErrTokenInvalid
+		}
+
+		// 原子地标记为已使用，并发使用同一 refresh token 时只有一个能成功
+		if ok, err := store.SetNX(ctx, refreshKey(claims.ID), a.remaining(claims)); err != nil {
+			return err
+		} else if !ok {
+			// refresh token 被重复使用，可能已泄露，吊销整个 token 族
+			if err := store.Set(ctx, familyKey(claims.Family), a.sessionExpiration()); err != nil {
+				return err
+			}
+			if err := store.DeleteSession(ctx, claims.Subject, claims.Family); err != nil {
+				return err
+			}
+			return ErrRefreshTokenReused
 		}
 		return nil
 	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return claims.Subject, nil
+	info, err := a.generateToken(claims.Subject, claims.Family, custom)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.saveSession(ctx, custom.jwtClaims(), true); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (a *JWTAuth) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(a.opts.keys))}
+	for _, key := range a.opts.keys {
+		if jwk, ok := newJWK(key); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
 }
 
 func (a *JWTAuth) Release(ctx context.Context) error {
@@ -2,20 +2,31 @@ package jwtx_test
 
 import (
 	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/gopkg-dev/karma/cachex"
 	"github.com/gopkg-dev/karma/jwtx"
 )
 
+func newAuth(t *testing.T, store jwtx.Store, opts ...jwtx.Option) jwtx.Auther {
+	t.Helper()
+	auth, err := jwtx.New(store, opts...)
+	assert.Nil(t, err)
+	return auth
+}
+
 func TestAuth(t *testing.T) {
 	cache := jwtx.NewMemoryCache(jwtx.MemoryConfig{CleanupInterval: time.Second})
 
 	store := jwtx.NewStoreWithCache(cache)
 	ctx := context.Background()
-	jwtAuth := jwtx.New(store)
+	jwtAuth := newAuth(t, store)
 
 	userID := "test"
 	token, err := jwtAuth.GenerateToken(ctx, userID)
@@ -37,3 +48,354 @@ func TestAuth(t *testing.T) {
 	err = jwtAuth.Release(ctx)
 	assert.Nil(t, err)
 }
+
+func TestRefreshToken(t *testing.T) {
+	cache := jwtx.NewMemoryCache(jwtx.MemoryConfig{CleanupInterval: time.Second})
+
+	store := jwtx.NewStoreWithCache(cache)
+	ctx := context.Background()
+	jwtAuth := newAuth(t, store, jwtx.SetExpired(60), jwtx.SetRefreshExpired(3600))
+
+	userID := "test"
+	token, err := jwtAuth.GenerateToken(ctx, userID)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, token.GetRefreshToken())
+	assert.Greater(t, token.GetRefreshExpiresAt(), token.GetExpiresAt())
+
+	// access token 与 refresh token 不能混用
+	_, err = jwtAuth.ParseSubject(ctx, token.GetRefreshToken())
+	assert.EqualError(t, err, jwtx.ErrTokenInvalid.Error())
+	_, err = jwtAuth.RefreshToken(ctx, token.GetAccessToken())
+	assert.EqualError(t, err, jwtx.ErrTokenInvalid.Error())
+
+	rotated, err := jwtAuth.RefreshToken(ctx, token.GetRefreshToken())
+	assert.Nil(t, err)
+	assert.NotEqual(t, token.GetRefreshToken(), rotated.GetRefreshToken())
+
+	id, err := jwtAuth.ParseSubject(ctx, rotated.GetAccessToken())
+	assert.Nil(t, err)
+	assert.Equal(t, userID, id)
+
+	// 重复使用已轮换的 refresh token 吊销整个 token 族
+	_, err = jwtAuth.RefreshToken(ctx, token.GetRefreshToken())
+	assert.EqualError(t, err, jwtx.ErrRefreshTokenReused.Error())
+
+	_, err = jwtAuth.RefreshToken(ctx, rotated.GetRefreshToken())
+	assert.EqualError(t, err, jwtx.ErrTokenInvalid.Error())
+	_, err = jwtAuth.ParseSubject(ctx, rotated.GetAccessToken())
+	assert.EqualError(t, err, jwtx.ErrTokenInvalid.Error())
+
+	// 退出登录后 refresh token 失效
+	token, err = jwtAuth.GenerateToken(ctx, userID)
+	assert.Nil(t, err)
+	assert.Nil(t, jwtAuth.DestroyToken(ctx, token.GetAccessToken()))
+	_, err = jwtAuth.RefreshToken(ctx, token.GetRefreshToken())
+	assert.EqualError(t, err, jwtx.ErrTokenInvalid.Error())
+
+	// 并发使用同一 refresh token 只有一个成功
+	token, err = jwtAuth.GenerateToken(ctx, userID)
+	assert.Nil(t, err)
+	var (
+		wg       sync.WaitGroup
+		rotation int32
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := jwtAuth.RefreshToken(ctx, token.GetRefreshToken()); err == nil {
+				atomic.AddInt32(&rotation, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), rotation)
+
+	// 默认不签发 refresh token
+	jwtAuth = newAuth(t, store)
+	token, err = jwtAuth.GenerateToken(ctx, userID)
+	assert.Nil(t, err)
+	assert.Empty(t, token.GetRefreshToken())
+
+	// 未配置 Store 时无法轮换及检测重复使用，不签发 refresh token
+	_, err = newAuth(t, nil, jwtx.SetRefreshExpired(3600)).GenerateToken(ctx, userID)
+	assert.True(t, errors.Is(err, jwtx.ErrStoreMissing))
+	_, err = newAuth(t, nil).RefreshToken(ctx, token.GetAccessToken())
+	assert.True(t, errors.Is(err, jwtx.ErrStoreMissing))
+
+	assert.Nil(t, jwtAuth.Release(ctx))
+}
+
+func TestStoreWithCachex(t *testing.T) {
+	ctx := context.Background()
+	cache := cachex.NewMemoryCache(cachex.MemoryConfig{CleanupInterval: time.Second})
+	jwtAuth := newAuth(t, jwtx.NewStoreWithCache(cache), jwtx.SetRefreshExpired(3600))
+
+	token, err := jwtAuth.GenerateToken(ctx, "test")
+	assert.Nil(t, err)
+	_, err = jwtAuth.RefreshToken(ctx, token.GetRefreshToken())
+	assert.Nil(t, err)
+	_, err = jwtAuth.RefreshToken(ctx, token.GetRefreshToken())
+	assert.EqualError(t, err, jwtx.ErrRefreshTokenReused.Error())
+	assert.Nil(t, jwtAuth.Release(ctx))
+}
+
+type userClaims struct {
+	jwtx.Claims
+	Roles    []string `json:"roles"`
+	TenantID string   `json:"tid"`
+}
+
+func TestCustomClaims(t *testing.T) {
+	cache := jwtx.NewMemoryCache(jwtx.MemoryConfig{CleanupInterval: time.Second})
+
+	store := jwtx.NewStoreWithCache(cache)
+	ctx := context.Background()
+	jwtAuth := newAuth(t, store, jwtx.SetRefreshExpired(3600))
+
+	token, err := jwtAuth.GenerateTokenWithClaims(ctx, "test", &userClaims{
+		Roles:    []string{"admin", "user"},
+		TenantID: "t1",
+	})
+	assert.Nil(t, err)
+
+	claims, err := jwtx.ParseCustomClaims[userClaims](ctx, jwtAuth, token.GetAccessToken())
+	assert.Nil(t, err)
+	assert.Equal(t, "test", claims.Subject)
+	assert.Equal(t, []string{"admin", "user"}, claims.Roles)
+	assert.Equal(t, "t1", claims.TenantID)
+	assert.NotEmpty(t, claims.ID)
+
+	id, err := jwtAuth.ParseSubject(ctx, token.GetAccessToken())
+	assert.Nil(t, err)
+	assert.Equal(t, "test", id)
+
+	// 轮换后保留自定义声明
+	rotated, err := jwtAuth.RefreshToken(ctx, token.GetRefreshToken())
+	assert.Nil(t, err)
+	claims = &userClaims{}
+	assert.Nil(t, jwtAuth.ParseClaims(ctx, rotated.GetAccessToken(), claims))
+	assert.Equal(t, "test", claims.Subject)
+	assert.Equal(t, []string{"admin", "user"}, claims.Roles)
+	assert.Equal(t, "t1", claims.TenantID)
+	assert.Empty(t, claims.Use)
+
+	assert.Nil(t, jwtAuth.Release(ctx))
+}
+
+type tenantClaims struct {
+	jwtx.Claims
+	TenantID string `json:"tid"`
+}
+
+func (c *tenantClaims) Valid() error {
+	if c.TenantID == "" {
+		return errors.New("tenant is required")
+	}
+	return nil
+}
+
+func TestValidateClaims(t *testing.T) {
+	ctx := context.Background()
+
+	auth := newAuth(t, nil, jwtx.SetIssuer("auth"), jwtx.SetAudience("orders", "billing"))
+	token, err := auth.GenerateToken(ctx, "test")
+	assert.Nil(t, err)
+
+	id, err := newAuth(t, nil, jwtx.SetIssuer("auth"), jwtx.SetAudience("billing")).ParseSubject(ctx, token.GetAccessToken())
+	assert.Nil(t, err)
+	assert.Equal(t, "test", id)
+
+	_, err = newAuth(t, nil, jwtx.SetIssuer("other")).ParseSubject(ctx, token.GetAccessToken())
+	assert.True(t, errors.Is(err, jwtx.ErrInvalidIssuer))
+	_, err = newAuth(t, nil, jwtx.SetAudience("users")).ParseSubject(ctx, token.GetAccessToken())
+	assert.True(t, errors.Is(err, jwtx.ErrInvalidAudience))
+	assert.False(t, errors.Is(err, jwtx.ErrInvalidIssuer))
+
+	_, err = newAuth(t, nil, jwtx.SetSigningKey("other")).ParseSubject(ctx, token.GetAccessToken())
+	assert.True(t, errors.Is(err, jwtx.ErrTokenSignatureInvalid))
+
+	// 允许时钟偏差
+	auth = newAuth(t, nil, jwtx.SetExpired(-5))
+	token, err = auth.GenerateToken(ctx, "test")
+	assert.Nil(t, err)
+	_, err = auth.ParseSubject(ctx, token.GetAccessToken())
+	assert.True(t, errors.Is(err, jwtx.ErrTokenExpired))
+	_, err = newAuth(t, nil, jwtx.SetLeeway(10*time.Second)).ParseSubject(ctx, token.GetAccessToken())
+	assert.Nil(t, err)
+
+	// 时钟偏差内退出登录，吊销记录仍会过期
+	cache := cachex.NewMemoryCache(cachex.MemoryConfig{CleanupInterval: time.Second})
+	auth = newAuth(t, jwtx.NewStoreWithCache(cache), jwtx.SetLeeway(10*time.Second))
+	claims, err := jwtx.ParseCustomClaims[jwtx.Claims](ctx, auth, token.GetAccessToken())
+	assert.Nil(t, err)
+	assert.Nil(t, auth.DestroyToken(ctx, token.GetAccessToken()))
+	ttl, ok, err := cache.(cachex.AtomicCacher).TTL(ctx, "jwt", "jti:"+claims.ID)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Greater(t, ttl, time.Duration(0))
+	assert.LessOrEqual(t, ttl, 10*time.Second)
+
+	// 自定义声明的 Valid 在标准声明验证之后调用
+	auth = newAuth(t, nil)
+	token, err = auth.GenerateTokenWithClaims(ctx, "test", &tenantClaims{})
+	assert.Nil(t, err)
+	err = auth.ParseClaims(ctx, token.GetAccessToken(), &tenantClaims{})
+	assert.True(t, errors.Is(err, jwtx.ErrTokenInvalid))
+	token, err = auth.GenerateTokenWithClaims(ctx, "test", &tenantClaims{TenantID: "t1"})
+	assert.Nil(t, err)
+	assert.Nil(t, auth.ParseClaims(ctx, token.GetAccessToken(), &tenantClaims{}))
+}
+
+func TestSessions(t *testing.T) {
+	cache := jwtx.NewMemoryCache(jwtx.MemoryConfig{CleanupInterval: time.Second})
+
+	store := jwtx.NewStoreWithCache(cache)
+	ctx := context.Background()
+	jwtAuth := newAuth(t, store, jwtx.SetRefreshExpired(3600))
+
+	userID := "test"
+	phone, err := jwtAuth.GenerateToken(jwtx.NewClientInfo(ctx, jwtx.ClientInfo{UserAgent: "phone", IP: "10.0.0.1"}), userID)
+	assert.Nil(t, err)
+	web, err := jwtAuth.GenerateToken(jwtx.NewClientInfo(ctx, jwtx.ClientInfo{UserAgent: "web", IP: "10.0.0.2"}), userID)
+	assert.Nil(t, err)
+	other, err := jwtAuth.GenerateToken(ctx, "other")
+	assert.Nil(t, err)
+
+	sessions, err := jwtAuth.ListSessions(ctx, userID)
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 2)
+	assert.Equal(t, "phone", sessions[0].UserAgent)
+	assert.Equal(t, "10.0.0.1", sessions[0].IP)
+	assert.Equal(t, "web", sessions[1].UserAgent)
+	assert.False(t, sessions[0].IssuedAt.IsZero())
+
+	// 轮换后更新会话的 jti，保留登录信息
+	claims, err := jwtx.ParseCustomClaims[jwtx.Claims](ctx, jwtAuth, web.GetAccessToken())
+	assert.Nil(t, err)
+	assert.Equal(t, claims.ID, sessions[1].TokenID)
+	refreshedAt := time.Now().UnixNano()
+	web, err = jwtAuth.RefreshToken(ctx, web.GetRefreshToken())
+	assert.Nil(t, err)
+	rotated, err := jwtx.ParseCustomClaims[jwtx.Claims](ctx, jwtAuth, web.GetAccessToken())
+	assert.Nil(t, err)
+	assert.GreaterOrEqual(t, rotated.IssuedAtNano, refreshedAt)
+	sessions, err = jwtAuth.ListSessions(ctx, userID)
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 2)
+	assert.Equal(t, "web", sessions[1].UserAgent)
+	assert.NotEqual(t, claims.ID, sessions[1].TokenID)
+
+	// 退出登录删除会话
+	assert.Nil(t, jwtAuth.DestroyToken(ctx, phone.GetAccessToken()))
+	sessions, err = jwtAuth.ListSessions(ctx, userID)
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, "web", sessions[0].UserAgent)
+
+	// 退出所有设备
+	assert.Nil(t, jwtAuth.RevokeAllForSubject(ctx, userID))
+	_, err = jwtAuth.ParseSubject(ctx, web.GetAccessToken())
+	assert.EqualError(t, err, jwtx.ErrTokenInvalid.Error())
+	_, err = jwtAuth.RefreshToken(ctx, web.GetRefreshToken())
+	assert.EqualError(t, err, jwtx.ErrTokenInvalid.Error())
+	sessions, err = jwtAuth.ListSessions(ctx, userID)
+	assert.Nil(t, err)
+	assert.Empty(t, sessions)
+
+	id, err := jwtAuth.ParseSubject(ctx, other.GetAccessToken())
+	assert.Nil(t, err)
+	assert.Equal(t, "other", id)
+
+	// 吊销后立即重新登录签发的 token 有效
+	token, err := jwtAuth.GenerateToken(ctx, userID)
+	assert.Nil(t, err)
+	id, err = jwtAuth.ParseSubject(ctx, token.GetAccessToken())
+	assert.Nil(t, err)
+	assert.Equal(t, userID, id)
+
+	// 旧版本以原始 token 为 key 的吊销记录仍然生效
+	assert.Nil(t, store.Set(ctx, token.GetAccessToken(), time.Minute))
+	_, err = jwtAuth.ParseSubject(ctx, token.GetAccessToken())
+	assert.EqualError(t, err, jwtx.ErrTokenInvalid.Error())
+
+	assert.Nil(t, jwtAuth.Release(ctx))
+}
+
+func TestRevokeSubjectSameSecond(t *testing.T) {
+	ctx := context.Background()
+	store := jwtx.NewStoreWithCache(jwtx.NewMemoryCache(jwtx.MemoryConfig{CleanupInterval: time.Second}))
+	jwtAuth := newAuth(t, store)
+
+	// 从新的一秒开始，保证 token 与吊销在同一秒内
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	token, err := jwtAuth.GenerateToken(ctx, "test")
+	assert.Nil(t, err)
+	sessions, err := jwtAuth.ListSessions(ctx, "test")
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 1)
+	// 会话已被覆盖，只能按签发时间吊销
+	assert.Nil(t, store.DeleteSession(ctx, "test", sessions[0].ID))
+
+	assert.Nil(t, jwtAuth.RevokeAllForSubject(ctx, "test"))
+	_, err = jwtAuth.ParseSubject(ctx, token.GetAccessToken())
+	assert.EqualError(t, err, jwtx.ErrTokenInvalid.Error())
+
+	// 修改密码后吊销所有设备并为当前设备签发新 token，同一秒内签发的新 token 有效
+	revoked, err := jwtx.ParseCustomClaims[jwtx.Claims](ctx, newAuth(t, nil), token.GetAccessToken())
+	assert.Nil(t, err)
+	token, err = jwtAuth.GenerateToken(ctx, "test")
+	assert.Nil(t, err)
+	claims, err := jwtx.ParseCustomClaims[jwtx.Claims](ctx, jwtAuth, token.GetAccessToken())
+	assert.Nil(t, err)
+	assert.Equal(t, revoked.IssuedAt.Unix(), claims.IssuedAt.Unix())
+	id, err := jwtAuth.ParseSubject(ctx, token.GetAccessToken())
+	assert.Nil(t, err)
+	assert.Equal(t, "test", id)
+
+	// 未配置 Store 时无法吊销
+	assert.True(t, errors.Is(newAuth(t, nil).RevokeAllForSubject(ctx, "test"), jwtx.ErrStoreMissing))
+}
+
+func TestSessionsLimit(t *testing.T) {
+	ctx := context.Background()
+	// 多个实例共享同一 cache
+	cache := cachex.NewMemoryCache(cachex.MemoryConfig{CleanupInterval: time.Second})
+	auth1 := newAuth(t, jwtx.NewStoreWithCache(cache, jwtx.WithMaxSessions(3)))
+	auth2 := newAuth(t, jwtx.NewStoreWithCache(cache, jwtx.WithMaxSessions(3)))
+
+	var wg sync.WaitGroup
+	for _, auth := range []jwtx.Auther{auth1, auth2, auth1} {
+		wg.Add(1)
+		go func(auth jwtx.Auther) {
+			defer wg.Done()
+			_, err := auth.GenerateToken(ctx, "test")
+			assert.Nil(t, err)
+		}(auth)
+	}
+	wg.Wait()
+	sessions, err := auth2.ListSessions(ctx, "test")
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 3)
+
+	// 超出上限时覆盖最早的会话
+	first := sessions[0].ID
+	token, err := auth2.GenerateToken(ctx, "test")
+	assert.Nil(t, err)
+	sessions, err = auth1.ListSessions(ctx, "test")
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 3)
+	for _, session := range sessions {
+		assert.NotEqual(t, first, session.ID)
+	}
+
+	// 优先使用空闲槽位，不覆盖仍然有效的会话
+	assert.Nil(t, auth1.DestroyToken(ctx, token.GetAccessToken()))
+	_, err = auth2.GenerateToken(ctx, "test")
+	assert.Nil(t, err)
+	latest, err := auth1.ListSessions(ctx, "test")
+	assert.Nil(t, err)
+	assert.Len(t, latest, 3)
+	assert.Equal(t, sessions[0].ID, latest[0].ID)
+	assert.Equal(t, sessions[1].ID, latest[1].ID)
+}
@@ -0,0 +1,105 @@
+package jwtx
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+	"os"
+
+	jwtV4 "github.com/golang-jwt/jwt/v4"
+)
+
+// Key 签名密钥，ID 写入 token 头部的 kid 用于验证时选择密钥
+type Key struct {
+	ID        string
+	Method    jwtV4.SigningMethod
+	signKey   interface{} // nil 表示仅用于验证
+	verifyKey interface{}
+}
+
+// CanSign 是否可用于签名
+func (k *Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+// PublicKey 返回非对称密钥的公钥，HMAC 密钥返回 nil
+func (k *Key) PublicKey() crypto.PublicKey {
+	switch key := k.verifyKey.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return key
+	}
+	return nil
+}
+
+// NewHMACKey 创建 HMAC(HS256/HS384/HS512) 密钥
+func NewHMACKey(id string, method jwtV4.SigningMethod, secret []byte) *Key {
+	return &Key{ID: id, Method: method, signKey: secret, verifyKey: secret}
+}
+
+// ParsePrivateKeyPEM 解析 PEM 格式的私钥，支持 RS*/PS*(PKCS1/PKCS8)、ES*(SEC1/PKCS8)、EdDSA(PKCS8)
+func ParsePrivateKeyPEM(id string, method jwtV4.SigningMethod, data []byte) (*Key, error) {
+	key := &Key{ID: id, Method: method}
+	switch method.(type) {
+	case *jwtV4.SigningMethodRSA, *jwtV4.SigningMethodRSAPSS:
+		pk, err := jwtV4.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		key.signKey, key.verifyKey = pk, &pk.PublicKey
+	case *jwtV4.SigningMethodECDSA:
+		pk, err := jwtV4.ParseECPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		key.signKey, key.verifyKey = pk, &pk.PublicKey
+	case *jwtV4.SigningMethodEd25519:
+		pk, err := jwtV4.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		key.signKey, key.verifyKey = pk, pk.(ed25519.PrivateKey).Public()
+	default:
+		return nil, fmt.Errorf("jwtx: unsupported signing method %s for PEM key", method.Alg())
+	}
+	return key, nil
+}
+
+// ParsePublicKeyPEM 解析 PEM 格式的公钥(PKIX 或证书)，得到仅用于验证的密钥
+func ParsePublicKeyPEM(id string, method jwtV4.SigningMethod, data []byte) (*Key, error) {
+	key := &Key{ID: id, Method: method}
+	var err error
+	switch method.(type) {
+	case *jwtV4.SigningMethodRSA, *jwtV4.SigningMethodRSAPSS:
+		key.verifyKey, err = jwtV4.ParseRSAPublicKeyFromPEM(data)
+	case *jwtV4.SigningMethodECDSA:
+		key.verifyKey, err = jwtV4.ParseECPublicKeyFromPEM(data)
+	case *jwtV4.SigningMethodEd25519:
+		key.verifyKey, err = jwtV4.ParseEdPublicKeyFromPEM(data)
+	default:
+		return nil, fmt.Errorf("jwtx: unsupported signing method %s for PEM key", method.Alg())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// LoadPrivateKeyFile 从文件加载 PEM 格式的私钥
+func LoadPrivateKeyFile(id string, method jwtV4.SigningMethod, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrivateKeyPEM(id, method, data)
+}
+
+// LoadPublicKeyFile 从文件加载 PEM 格式的公钥
+func LoadPublicKeyFile(id string, method jwtV4.SigningMethod, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePublicKeyPEM(id, method, data)
+}
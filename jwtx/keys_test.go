@@ -0,0 +1,143 @@
+package jwtx_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	jwtV4 "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gopkg-dev/karma/encoding/json"
+	"github.com/gopkg-dev/karma/jwtx"
+)
+
+func encodePEM(t *testing.T, key interface{}) ([]byte, []byte) {
+	priv, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.Nil(t, err)
+	pub, err := x509.MarshalPKIXPublicKey(key.(interface{ Public() crypto.PublicKey }).Public())
+	assert.Nil(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: priv}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub})
+}
+
+func TestAsymmetricKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		method jwtV4.SigningMethod
+		key    interface{}
+		kty    string
+	}{
+		{jwtV4.SigningMethodRS256, rsaKey, "RSA"},
+		{jwtV4.SigningMethodES256, ecKey, "EC"},
+		{jwtV4.SigningMethodEdDSA, edKey, "OKP"},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.method.Alg(), func(t *testing.T) {
+			privPEM, pubPEM := encodePEM(t, tt.key)
+			key, err := jwtx.ParsePrivateKeyPEM("k1", tt.method, privPEM)
+			assert.Nil(t, err)
+			assert.True(t, key.CanSign())
+
+			auth := newAuth(t, nil, jwtx.SetKeys(key))
+			token, err := auth.GenerateToken(ctx, "test")
+			assert.Nil(t, err)
+			id, err := auth.ParseSubject(ctx, token.GetAccessToken())
+			assert.Nil(t, err)
+			assert.Equal(t, "test", id)
+
+			// 其他服务只持有公钥即可验证
+			pub, err := jwtx.ParsePublicKeyPEM("k1", tt.method, pubPEM)
+			assert.Nil(t, err)
+			assert.False(t, pub.CanSign())
+			verifier := newAuth(t, nil, jwtx.SetVerifyKeys(pub))
+			id, err = verifier.ParseSubject(ctx, token.GetAccessToken())
+			assert.Nil(t, err)
+			assert.Equal(t, "test", id)
+			_, err = verifier.GenerateToken(ctx, "test")
+			assert.EqualError(t, err, jwtx.ErrSigningKeyMissing.Error())
+
+			// 当前密钥必须可用于签名
+			_, err = jwtx.New(nil, jwtx.SetKeys(pub))
+			assert.NotNil(t, err)
+			_, err = jwtx.New(nil, jwtx.SetKeys(nil))
+			assert.NotNil(t, err)
+
+			set := auth.JWKS()
+			assert.Len(t, set.Keys, 1)
+			assert.Equal(t, "k1", set.Keys[0].Kid)
+			assert.Equal(t, tt.kty, set.Keys[0].Kty)
+			assert.Equal(t, tt.method.Alg(), set.Keys[0].Alg)
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	newKey := func(id string) *jwtx.Key {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.Nil(t, err)
+		privPEM, _ := encodePEM(t, k)
+		key, err := jwtx.ParsePrivateKeyPEM(id, jwtV4.SigningMethodES256, privPEM)
+		assert.Nil(t, err)
+		return key
+	}
+	k1, k2 := newKey("k1"), newKey("k2")
+
+	old := newAuth(t, nil, jwtx.SetKeys(k1))
+	token, err := old.GenerateToken(ctx, "test")
+	assert.Nil(t, err)
+
+	// k2 签发新 token，k1 签发的 token 仍然有效
+	auth := newAuth(t, nil, jwtx.SetKeys(k2, k1))
+	id, err := auth.ParseSubject(ctx, token.GetAccessToken())
+	assert.Nil(t, err)
+	assert.Equal(t, "test", id)
+
+	token2, err := auth.GenerateToken(ctx, "test")
+	assert.Nil(t, err)
+	_, err = old.ParseSubject(ctx, token2.GetAccessToken())
+	assert.EqualError(t, err, jwtx.ErrUnknownKeyID.Error())
+
+	// HMAC 签名的 token 不能通过公钥验证
+	_, err = auth.ParseSubject(ctx, func() string {
+		hs, err := newAuth(t, nil).GenerateToken(ctx, "test")
+		assert.Nil(t, err)
+		return hs.GetAccessToken()
+	}())
+	assert.EqualError(t, err, jwtx.ErrUnknownKeyID.Error())
+
+	app := fiber.New()
+	app.Get("/.well-known/jwks.json", jwtx.JWKSHandler(auth))
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, fiber.MIMEApplicationJSON, resp.Header.Get(fiber.HeaderContentType))
+
+	var set jwtx.JWKSet
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&set))
+	assert.Len(t, set.Keys, 2)
+	assert.Equal(t, "k1", set.Keys[0].Kid)
+	assert.Equal(t, "k2", set.Keys[1].Kid)
+	assert.Equal(t, "P-256", set.Keys[0].Crv)
+
+	// HMAC 密钥不公开
+	assert.Empty(t, newAuth(t, nil).JWKS().Keys)
+}
@@ -46,6 +46,29 @@ func (a *memCache) Get(ctx context.Context, ns, key string) (string, bool, error
 	return val.(string), ok, nil
 }
 
+func (a *memCache) SetNX(ctx context.Context, ns, key, value string, expiration ...time.Duration) (bool, error) {
+	var exp time.Duration
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+
+	if err := a.cache.Add(a.getKey(ns, key), value, exp); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+func (a *memCache) Incr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	var exp time.Duration
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+
+	k := a.getKey(ns, key)
+	_ = a.cache.Add(k, int64(0), exp)
+	return a.cache.IncrementInt64(k, 1)
+}
+
 func (a *memCache) Exists(ctx context.Context, ns, key string) (bool, error) {
 	_, ok := a.cache.Get(a.getKey(ns, key))
 	return ok, nil
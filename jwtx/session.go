@@ -0,0 +1,38 @@
+package jwtx
+
+import (
+	"context"
+	"time"
+)
+
+// Session 登录会话，同一次登录及其后轮换出的 token 属于同一会话
+type Session struct {
+	ID          string    `json:"id"` // 会话 ID，即 token 族
+	Subject     string    `json:"subject"`
+	TokenID     string    `json:"tokenId"`     // 最近签发的 access token 的 jti
+	IssuedAt    time.Time `json:"issuedAt"`    // 登录时间
+	RefreshedAt time.Time `json:"refreshedAt"` // 最近签发 token 的时间
+	ExpiresAt   time.Time `json:"expiresAt"`
+	UserAgent   string    `json:"userAgent,omitempty"`
+	IP          string    `json:"ip,omitempty"`
+}
+
+// ClientInfo 客户端信息，签发 token 时记录到会话
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+type clientInfoCtx struct{}
+
+func NewClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoCtx{}, info)
+}
+
+func FromClientInfo(ctx context.Context) (ClientInfo, bool) {
+	v := ctx.Value(clientInfoCtx{})
+	if v != nil {
+		return v.(ClientInfo), true
+	}
+	return ClientInfo{}, false
+}
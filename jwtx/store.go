@@ -2,19 +2,41 @@ package jwtx
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strconv"
 	"time"
+
+	"github.com/gopkg-dev/karma/encoding/json"
 )
 
 // Store is the interface that storage the token.
 type Store interface {
-	Set(ctx context.Context, tokenStr string, expiration time.Duration) error
-	Delete(ctx context.Context, tokenStr string) error
-	Check(ctx context.Context, tokenStr string) (bool, error)
+	// Set 记录吊销的 key（jti、token 族等），expiration 后自动清除
+	Set(ctx context.Context, key string, expiration time.Duration) error
+	// SetNX key 不存在时记录，返回是否记录成功，用于原子地标记 refresh token 已使用
+	SetNX(ctx context.Context, key string, expiration time.Duration) (bool, error)
+	Delete(ctx context.Context, key string) error
+	Check(ctx context.Context, key string) (bool, error)
+
+	// SaveSession 新增或更新 subject 的会话，会话在 ExpiresAt 后自动清除；
+	// 每个 subject 最多保留 MaxSessions 个会话，超出时覆盖最早的会话（其 token 仍可由 RevokeAllForSubject 吊销）
+	SaveSession(ctx context.Context, session *Session) error
+	// ListSessions 按登录时间列出 subject 未过期的会话
+	ListSessions(ctx context.Context, subject string) ([]*Session, error)
+	// DeleteSession 删除 subject 的会话
+	DeleteSession(ctx context.Context, subject string, ids ...string) error
+	// RevokeSubject 使 subject 在 at 及之前签发的 token 全部失效，精确到纳秒
+	RevokeSubject(ctx context.Context, subject string, at time.Time, expiration time.Duration) error
+	// RevokedAt 返回 subject 的吊销时间
+	RevokedAt(ctx context.Context, subject string) (time.Time, bool, error)
+
 	Close(ctx context.Context) error
 }
 
 type storeOptions struct {
-	CacheNS string // default "jwt"
+	CacheNS     string // default "jwt"
+	MaxSessions int    // default 10
 }
 
 type StoreOption func(*storeOptions)
@@ -25,6 +47,13 @@ func WithCacheNS(ns string) StoreOption {
 	}
 }
 
+// WithMaxSessions 设置每个 subject 最多保留的会话数
+func WithMaxSessions(n int) StoreOption {
+	return func(o *storeOptions) {
+		o.MaxSessions = n
+	}
+}
+
 type Cache interface {
 	Set(ctx context.Context, ns, key, value string, expiration ...time.Duration) error
 	Get(ctx context.Context, ns, key string) (string, bool, error)
@@ -33,16 +62,32 @@ type Cache interface {
 	Close(ctx context.Context) error
 }
 
+// AtomicCache 原子操作，cachex 的 cache 驱动及 NewMemoryCache 均已实现；
+// 未实现时无法使用 refresh token，并发登录的会话可能相互覆盖
+type AtomicCache interface {
+	SetNX(ctx context.Context, ns, key, value string, expiration ...time.Duration) (bool, error)
+	Incr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error)
+}
+
+// batchGetter 批量读取，cachex 的 cache 驱动均已实现
+type batchGetter interface {
+	MGet(ctx context.Context, ns string, keys ...string) (map[string]string, error)
+}
+
 func NewStoreWithCache(cache Cache, opts ...StoreOption) Store {
 	s := &storeImpl{
 		c: cache,
 		opts: &storeOptions{
-			CacheNS: "jwt",
+			CacheNS:     "jwt",
+			MaxSessions: 10,
 		},
 	}
 	for _, opt := range opts {
 		opt(s.opts)
 	}
+	if s.opts.MaxSessions <= 0 {
+		s.opts.MaxSessions = 10
+	}
 	return s
 }
 
@@ -51,16 +96,187 @@ type storeImpl struct {
 	c    Cache
 }
 
-func (s *storeImpl) Set(ctx context.Context, tokenStr string, expiration time.Duration) error {
-	return s.c.Set(ctx, s.opts.CacheNS, tokenStr, "", expiration)
+func (s *storeImpl) Set(ctx context.Context, key string, expiration time.Duration) error {
+	return s.c.Set(ctx, s.opts.CacheNS, key, "", expiration)
+}
+
+func (s *storeImpl) SetNX(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	ac, ok := s.c.(AtomicCache)
+	if !ok {
+		return false, fmt.Errorf("jwtx: %T does not implement AtomicCache", s.c)
+	}
+	return ac.SetNX(ctx, s.opts.CacheNS, key, "", expiration)
+}
+
+func (s *storeImpl) Delete(ctx context.Context, key string) error {
+	return s.c.Delete(ctx, s.opts.CacheNS, key)
+}
+
+func (s *storeImpl) Check(ctx context.Context, key string) (bool, error) {
+	return s.c.Exists(ctx, s.opts.CacheNS, key)
+}
+
+// sessionSeqKey 会话计数器，用于原子地分配会话槽位
+func sessionSeqKey(subject string) string {
+	return "sessions:" + subject + ":seq"
 }
 
-func (s *storeImpl) Delete(ctx context.Context, tokenStr string) error {
-	return s.c.Delete(ctx, s.opts.CacheNS, tokenStr)
+// sessionKey 会话槽位，每个会话单独存储，槽位循环使用
+func sessionKey(subject string, slot int) string {
+	return "sessions:" + subject + ":" + strconv.Itoa(slot)
 }
 
-func (s *storeImpl) Check(ctx context.Context, tokenStr string) (bool, error) {
-	return s.c.Exists(ctx, s.opts.CacheNS, tokenStr)
+func subjectKey(subject string) string {
+	return "subject:" + subject
+}
+
+// loadSessions 读取 subject 各槽位中未过期的会话
+func (s *storeImpl) loadSessions(ctx context.Context, subject string) (map[int]*Session, error) {
+	keys := make([]string, s.opts.MaxSessions)
+	for i := range keys {
+		keys[i] = sessionKey(subject, i)
+	}
+
+	values := make(map[string]string, len(keys))
+	if bg, ok := s.c.(batchGetter); ok {
+		var err error
+		if values, err = bg.MGet(ctx, s.opts.CacheNS, keys...); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, key := range keys {
+			val, ok, err := s.c.Get(ctx, s.opts.CacheNS, key)
+			if err != nil {
+				return nil, err
+			} else if ok {
+				values[key] = val
+			}
+		}
+	}
+
+	now := time.Now()
+	sessions := make(map[int]*Session, len(values))
+	for slot, key := range keys {
+		val, ok := values[key]
+		if !ok {
+			continue
+		}
+		session := &Session{}
+		if err := json.UnmarshalString(val, session); err != nil {
+			return nil, err
+		}
+		if session.ExpiresAt.After(now) {
+			sessions[slot] = session
+		}
+	}
+	return sessions, nil
+}
+
+func (s *storeImpl) SaveSession(ctx context.Context, session *Session) error {
+	sessions, err := s.loadSessions(ctx, session.Subject)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	expiration := time.Until(session.ExpiresAt)
+
+	slot := -1
+	for i, v := range sessions {
+		if v.ID == session.ID {
+			slot = i
+			break
+		}
+	}
+	if slot < 0 {
+		var saved bool
+		if slot, saved, err = s.allocSlot(ctx, session.Subject, string(b), expiration, sessions); err != nil || saved {
+			return err
+		}
+	}
+	return s.c.Set(ctx, s.opts.CacheNS, sessionKey(session.Subject, slot), string(b), expiration)
+}
+
+// allocSlot 为新会话分配槽位，优先使用空闲槽位，槽位用完后覆盖最早的会话；
+// 实现 AtomicCache 时以 SetNX 直接写入空闲槽位，saved 表示会话已保存
+func (s *storeImpl) allocSlot(ctx context.Context, subject, value string, expiration time.Duration, sessions map[int]*Session) (slot int, saved bool, err error) {
+	if ac, ok := s.c.(AtomicCache); ok {
+		// 并发登录（包括多个实例）时同一空闲槽位只有一个能写入成功
+		for i := 0; i < s.opts.MaxSessions; i++ {
+			if _, ok := sessions[i]; ok {
+				continue
+			}
+			if saved, err := ac.SetNX(ctx, s.opts.CacheNS, sessionKey(subject, i), value, expiration); err != nil || saved {
+				return i, saved, err
+			}
+		}
+		// 没有空闲槽位时由计数器轮流覆盖，保证并发登录覆盖不同的槽位
+		n, err := ac.Incr(ctx, s.opts.CacheNS, sessionSeqKey(subject), expiration)
+		if err != nil {
+			return 0, false, err
+		}
+		return int((n - 1) % int64(s.opts.MaxSessions)), false, nil
+	}
+
+	for i := 0; i < s.opts.MaxSessions; i++ {
+		v, ok := sessions[i]
+		if !ok {
+			return i, false, nil
+		} else if v.IssuedAt.Before(sessions[slot].IssuedAt) {
+			slot = i
+		}
+	}
+	return slot, false, nil
+}
+
+func (s *storeImpl) ListSessions(ctx context.Context, subject string) ([]*Session, error) {
+	sessions, err := s.loadSessions(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*Session, 0, len(sessions))
+	for _, session := range sessions {
+		list = append(list, session)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].IssuedAt.Before(list[j].IssuedAt) })
+	return list, nil
+}
+
+func (s *storeImpl) DeleteSession(ctx context.Context, subject string, ids ...string) error {
+	sessions, err := s.loadSessions(ctx, subject)
+	if err != nil {
+		return err
+	}
+	for slot, session := range sessions {
+		for _, id := range ids {
+			if session.ID == id {
+				if err := s.c.Delete(ctx, s.opts.CacheNS, sessionKey(subject, slot)); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (s *storeImpl) RevokeSubject(ctx context.Context, subject string, at time.Time, expiration time.Duration) error {
+	return s.c.Set(ctx, s.opts.CacheNS, subjectKey(subject), strconv.FormatInt(at.UnixNano(), 10), expiration)
+}
+
+func (s *storeImpl) RevokedAt(ctx context.Context, subject string) (time.Time, bool, error) {
+	val, ok, err := s.c.Get(ctx, s.opts.CacheNS, subjectKey(subject))
+	if err != nil || !ok {
+		return time.Time{}, false, err
+	}
+	nsec, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return time.Unix(0, nsec), true, nil
 }
 
 func (s *storeImpl) Close(ctx context.Context) error {
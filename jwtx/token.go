@@ -8,6 +8,8 @@ type TokenInfo interface {
 	GetAccessToken() string
 	GetTokenType() string
 	GetExpiresAt() int64
+	GetRefreshToken() string
+	GetRefreshExpiresAt() int64
 	EncodeToJSON() ([]byte, error)
 }
 
@@ -15,6 +17,9 @@ type tokenInfo struct {
 	AccessToken string `json:"accessToken"`
 	TokenType   string `json:"tokenType"`
 	ExpiresAt   int64  `json:"expiresAt"`
+
+	RefreshToken     string `json:"refreshToken,omitempty"`
+	RefreshExpiresAt int64  `json:"refreshExpiresAt,omitempty"`
 }
 
 func (t *tokenInfo) GetAccessToken() string {
@@ -29,6 +34,14 @@ func (t *tokenInfo) GetExpiresAt() int64 {
 	return t.ExpiresAt
 }
 
+func (t *tokenInfo) GetRefreshToken() string {
+	return t.RefreshToken
+}
+
+func (t *tokenInfo) GetRefreshExpiresAt() int64 {
+	return t.RefreshExpiresAt
+}
+
 func (t *tokenInfo) EncodeToJSON() ([]byte, error) {
 	return jsoniter.Marshal(t)
 }
@@ -0,0 +1,139 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gopkg-dev/karma/log"
+	"github.com/gopkg-dev/karma/util"
+)
+
+const defaultBufferSize = 64
+
+type MemoryConfig struct {
+	BufferSize int // 每个订阅的缓冲队列长度,默认 64,队列满时 Publish 阻塞
+}
+
+// NewMemory 创建进程内的发布订阅,消息不持久化,handler 返回错误时只记录日志
+func NewMemory(cfg MemoryConfig) PubSub {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = defaultBufferSize
+	}
+	return &memoryPubSub{
+		cfg:  cfg,
+		subs: make(map[string]map[*memorySubscription]struct{}),
+	}
+}
+
+type memoryPubSub struct {
+	cfg    MemoryConfig
+	mu     sync.RWMutex
+	subs   map[string]map[*memorySubscription]struct{}
+	closed bool
+}
+
+type memorySubscription struct {
+	ps    *memoryPubSub
+	topic string
+	ch    chan *Message
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+func (a *memoryPubSub) Publish(ctx context.Context, msg *Message) error {
+	if msg.ID == "" {
+		msg.ID = util.NewXID()
+	}
+
+	// 复制订阅后释放锁,避免队列满时阻塞 handler 中的 Subscribe/Unsubscribe/Publish
+	a.mu.RLock()
+	if a.closed {
+		a.mu.RUnlock()
+		return ErrClosed
+	}
+	subs := make([]*memorySubscription, 0, len(a.subs[msg.Topic]))
+	for sub := range a.subs[msg.Topic] {
+		subs = append(subs, sub)
+	}
+	a.mu.RUnlock()
+
+	for _, sub := range subs {
+		select {
+		case sub.ch <- msg:
+		case <-sub.stop:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (a *memoryPubSub) Subscribe(ctx context.Context, topic string, handler Handler) (Subscription, error) {
+	sub := &memorySubscription{
+		ps:    a,
+		topic: topic,
+		ch:    make(chan *Message, a.cfg.BufferSize),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if a.subs[topic] == nil {
+		a.subs[topic] = make(map[*memorySubscription]struct{})
+	}
+	a.subs[topic][sub] = struct{}{}
+	a.mu.Unlock()
+
+	go sub.run(handlerContext(context.WithoutCancel(ctx), sub), handler)
+	return sub, nil
+}
+
+func (s *memorySubscription) run(ctx context.Context, handler Handler) {
+	defer close(s.done)
+	for {
+		select {
+		case <-s.stop:
+			return
+		case msg := <-s.ch:
+			if err := handler(ctx, msg); err != nil {
+				log.Context(ctx).Warnf("pubsub: handle %s message %s: %v", msg.Topic, msg.ID, err)
+			}
+		}
+	}
+}
+
+func (s *memorySubscription) Unsubscribe(ctx context.Context) error {
+	s.once.Do(func() {
+		// 先停止接收,阻塞中的 Publish 不再等待该订阅
+		close(s.stop)
+		s.ps.mu.Lock()
+		delete(s.ps.subs[s.topic], s)
+		if len(s.ps.subs[s.topic]) == 0 {
+			delete(s.ps.subs, s.topic)
+		}
+		s.ps.mu.Unlock()
+	})
+	return waitHandler(ctx, s, s.done)
+}
+
+func (a *memoryPubSub) Close() error {
+	a.mu.Lock()
+	a.closed = true
+	var subs []*memorySubscription
+	for _, m := range a.subs {
+		for sub := range m {
+			subs = append(subs, sub)
+		}
+	}
+	a.mu.Unlock()
+
+	for _, sub := range subs {
+		_ = sub.Unsubscribe(context.Background())
+	}
+	return nil
+}
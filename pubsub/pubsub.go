@@ -0,0 +1,71 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrClosed 在已关闭的 Publisher/Subscriber 上操作
+var ErrClosed = errors.New("pubsub: closed")
+
+// Message 事件消息
+type Message struct {
+	ID       string            // 消息ID,发布时生成
+	Topic    string            // 主题
+	Payload  []byte            // 消息内容
+	Metadata map[string]string // 附加信息,如 trace_id
+}
+
+// NewMessage 创建消息
+func NewMessage(topic string, payload []byte) *Message {
+	return &Message{Topic: topic, Payload: payload}
+}
+
+// Handler 处理消息,返回 nil 表示确认(ack),支持重新投递的实现会在返回错误后再次投递
+type Handler func(ctx context.Context, msg *Message) error
+
+// Publisher 发布消息
+type Publisher interface {
+	Publish(ctx context.Context, msg *Message) error
+	Close() error
+}
+
+// Subscriber 订阅消息,每个订阅在独立的 goroutine 中顺序调用 handler
+type Subscriber interface {
+	Subscribe(ctx context.Context, topic string, handler Handler) (Subscription, error)
+	Close() error
+}
+
+// Subscription 订阅句柄
+type Subscription interface {
+	// Unsubscribe 取消订阅并等待该订阅正在处理的消息完成,ctx 结束时不再等待并返回 ctx.Err();
+	// 在 handler 中以 handler 收到的 ctx 取消自身订阅时不等待(当前消息处理完成后不再投递)
+	Unsubscribe(ctx context.Context) error
+}
+
+// handlerKey 传给 handler 的 ctx 中保存当前订阅,Unsubscribe 据此判断是否在自身的 handler 中调用
+type handlerKey struct{}
+
+// handlerContext 返回传给 sub 的 handler 的 ctx
+func handlerContext(ctx context.Context, sub Subscription) context.Context {
+	return context.WithValue(ctx, handlerKey{}, sub)
+}
+
+// waitHandler 等待 done 关闭,ctx 来自 sub 自身的 handler 时直接返回
+func waitHandler(ctx context.Context, sub Subscription, done <-chan struct{}) error {
+	if ctx.Value(handlerKey{}) == sub {
+		return nil
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PubSub 同时支持发布和订阅
+type PubSub interface {
+	Publisher
+	Subscriber
+}
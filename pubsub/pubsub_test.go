@@ -0,0 +1,234 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRedisClient(t *testing.T) *redis.Client {
+	cli := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 1})
+	if err := cli.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("redis is not available: %v", err)
+	}
+	return cli
+}
+
+type recorder struct {
+	mu   sync.Mutex
+	msgs []*Message
+}
+
+func (r *recorder) handle(ctx context.Context, msg *Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.msgs = append(r.msgs, msg)
+	return nil
+}
+
+func (r *recorder) len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.msgs)
+}
+
+func testPubSub(t *testing.T, ps PubSub) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	r1, r2 := &recorder{}, &recorder{}
+	s1, err := ps.Subscribe(ctx, "user.updated", r1.handle)
+	assert.Nil(err)
+	s2, err := ps.Subscribe(ctx, "user.updated", r2.handle)
+	assert.Nil(err)
+
+	msg := NewMessage("user.updated", []byte(`{"id":1}`))
+	msg.Metadata = map[string]string{"trace_id": "abc"}
+	assert.Nil(ps.Publish(ctx, msg))
+	assert.NotEmpty(msg.ID)
+	assert.Nil(ps.Publish(ctx, NewMessage("config.changed", []byte("x"))))
+
+	assert.Eventually(func() bool { return r1.len() == 1 && r2.len() == 1 }, time.Second, 10*time.Millisecond)
+	r1.mu.Lock()
+	assert.Equal(msg.ID, r1.msgs[0].ID)
+	assert.Equal("user.updated", r1.msgs[0].Topic)
+	assert.Equal([]byte(`{"id":1}`), r1.msgs[0].Payload)
+	assert.Equal("abc", r1.msgs[0].Metadata["trace_id"])
+	r1.mu.Unlock()
+
+	assert.Nil(s1.Unsubscribe(ctx))
+	assert.Nil(ps.Publish(ctx, NewMessage("user.updated", []byte("2"))))
+	assert.Eventually(func() bool { return r2.len() == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(1, r1.len())
+
+	assert.Nil(s2.Unsubscribe(ctx))
+
+	// unsubscribing from inside the handler does not wait for the handler itself
+	var (
+		self Subscription
+		once sync.Once
+	)
+	ready, unsubscribed := make(chan struct{}), make(chan struct{})
+	self, err = ps.Subscribe(ctx, "config.changed", func(ctx context.Context, msg *Message) error {
+		<-ready
+		once.Do(func() {
+			assert.Nil(self.Unsubscribe(ctx))
+			close(unsubscribed)
+		})
+		return nil
+	})
+	assert.Nil(err)
+	close(ready)
+	assert.Nil(ps.Publish(ctx, NewMessage("config.changed", []byte("x"))))
+	select {
+	case <-unsubscribed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("unsubscribe from handler blocked")
+	}
+
+	// other callers wait for the running handler until ctx is done
+	started, release := make(chan struct{}), make(chan struct{})
+	slow, err := ps.Subscribe(ctx, "config.changed", func(ctx context.Context, msg *Message) error {
+		close(started)
+		<-release
+		return nil
+	})
+	assert.Nil(err)
+	assert.Nil(ps.Publish(ctx, NewMessage("config.changed", []byte("y"))))
+	<-started
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	assert.ErrorIs(slow.Unsubscribe(tctx), context.DeadlineExceeded)
+	cancel()
+	close(release)
+	assert.Nil(slow.Unsubscribe(ctx))
+
+	assert.Nil(ps.Close())
+}
+
+func TestMemory(t *testing.T) {
+	testPubSub(t, NewMemory(MemoryConfig{}))
+
+	// handlers may subscribe and publish while Publish waits on a full buffer
+	ps := NewMemory(MemoryConfig{BufferSize: 1})
+	r := &recorder{}
+	release := make(chan struct{})
+	_, err := ps.Subscribe(context.Background(), "a", func(ctx context.Context, msg *Message) error {
+		<-release
+		if _, err := ps.Subscribe(ctx, "b", r.handle); err != nil {
+			return err
+		}
+		return ps.Publish(ctx, NewMessage("b", msg.Payload))
+	})
+	assert.Nil(t, err)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			assert.Nil(t, ps.Publish(context.Background(), NewMessage("a", nil)))
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publish deadlocked")
+	}
+	assert.Eventually(t, func() bool { return r.len() > 0 }, time.Second, 10*time.Millisecond)
+	assert.Nil(t, ps.Close())
+	assert.ErrorIs(t, ps.Publish(context.Background(), NewMessage("a", nil)), ErrClosed)
+}
+
+func TestRedis(t *testing.T) {
+	testPubSub(t, NewRedisWithClient(newTestRedisClient(t)))
+}
+
+func TestRedisStream(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	cli := newTestRedisClient(t)
+	assert.Nil(cli.Del(ctx, "orders", "user.updated", "config.changed").Err())
+
+	cfg := StreamConfig{
+		Group:         "billing",
+		Block:         50 * time.Millisecond,
+		MinIdle:       100 * time.Millisecond,
+		ClaimInterval: 50 * time.Millisecond,
+	}
+	testPubSub(t, NewRedisStreamWithClient(newTestRedisClient(t), cfg))
+
+	ps := NewRedisStreamWithClient(cli, cfg)
+	testPubSubStream(t, ps)
+
+	// consumers in the same group share the messages
+	cfg.Consumer = "c1"
+	c1 := NewRedisStreamWithClient(newTestRedisClient(t), cfg)
+	cfg.Consumer = "c2"
+	c2 := NewRedisStreamWithClient(newTestRedisClient(t), cfg)
+
+	r := &recorder{}
+	s1, err := c1.Subscribe(ctx, "orders", r.handle)
+	assert.Nil(err)
+	s2, err := c2.Subscribe(ctx, "orders", r.handle)
+	assert.Nil(err)
+	for i := 0; i < 10; i++ {
+		assert.Nil(c1.Publish(ctx, NewMessage("orders", []byte("o"))))
+	}
+	assert.Eventually(func() bool { return r.len() == 10 }, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(10, r.len())
+
+	assert.Nil(s1.Unsubscribe(ctx))
+	assert.Nil(s2.Unsubscribe(ctx))
+	assert.Nil(c1.Close())
+	assert.Nil(c2.Close())
+}
+
+func testPubSubStream(t *testing.T, ps PubSub) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	// handler failure leaves the message pending and it is redelivered
+	var (
+		mu       sync.Mutex
+		attempts int
+	)
+	sub, err := ps.Subscribe(ctx, "orders", func(ctx context.Context, msg *Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			return errors.New("temporary failure")
+		}
+		assert.Equal([]byte("order-1"), msg.Payload)
+		assert.Equal("abc", msg.Metadata["trace_id"])
+		return nil
+	})
+	assert.Nil(err)
+
+	msg := NewMessage("orders", []byte("order-1"))
+	msg.Metadata = map[string]string{"trace_id": "abc"}
+	assert.Nil(ps.Publish(ctx, msg))
+	assert.NotEmpty(msg.ID)
+
+	assert.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return attempts == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	cli := newTestRedisClient(t)
+	assert.Eventually(func() bool {
+		pending, err := cli.XPending(ctx, "orders", "billing").Result()
+		return err == nil && pending.Count == 0
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Nil(sub.Unsubscribe(ctx))
+	assert.Nil(ps.Close())
+}
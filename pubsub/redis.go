@@ -0,0 +1,121 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/gopkg-dev/karma/cachex"
+	"github.com/gopkg-dev/karma/encoding/json"
+	"github.com/gopkg-dev/karma/log"
+	"github.com/gopkg-dev/karma/util"
+)
+
+// NewRedis 基于 Redis PUBLISH/SUBSCRIBE 的发布订阅,消息只投递给在线的订阅者
+func NewRedis(cfg cachex.RedisConfig) (PubSub, error) {
+	cli, err := cachex.NewRedisClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewRedisWithClient(cli), nil
+}
+
+// NewRedisWithClient Use redis client create pub/sub
+func NewRedisWithClient(cli redis.UniversalClient) PubSub {
+	return &redisPubSub{cli: cli, subs: make(map[*redisSubscription]struct{})}
+}
+
+type redisPubSub struct {
+	cli  redis.UniversalClient
+	mu   sync.Mutex
+	subs map[*redisSubscription]struct{}
+}
+
+// envelope Redis pub/sub 消息格式
+type envelope struct {
+	ID       string            `json:"i"`
+	Payload  []byte            `json:"p"`
+	Metadata map[string]string `json:"m,omitempty"`
+}
+
+func (a *redisPubSub) Publish(ctx context.Context, msg *Message) error {
+	if msg.ID == "" {
+		msg.ID = util.NewXID()
+	}
+	b, err := json.Marshal(envelope{ID: msg.ID, Payload: msg.Payload, Metadata: msg.Metadata})
+	if err != nil {
+		return err
+	}
+	return a.cli.Publish(ctx, msg.Topic, b).Err()
+}
+
+func (a *redisPubSub) Subscribe(ctx context.Context, topic string, handler Handler) (Subscription, error) {
+	ps := a.cli.Subscribe(ctx, topic)
+	// 等待订阅生效,避免丢失订阅后立即发布的消息
+	if _, err := ps.Receive(ctx); err != nil {
+		_ = ps.Close()
+		return nil, err
+	}
+
+	sub := &redisSubscription{
+		owner: a,
+		ps:    ps,
+		done:  make(chan struct{}),
+	}
+	a.mu.Lock()
+	a.subs[sub] = struct{}{}
+	a.mu.Unlock()
+	go sub.run(handlerContext(context.WithoutCancel(ctx), sub), topic, handler)
+	return sub, nil
+}
+
+func (a *redisPubSub) Close() error {
+	a.mu.Lock()
+	subs := make([]*redisSubscription, 0, len(a.subs))
+	for sub := range a.subs {
+		subs = append(subs, sub)
+	}
+	a.mu.Unlock()
+
+	for _, sub := range subs {
+		_ = sub.Unsubscribe(context.Background())
+	}
+	return a.cli.Close()
+}
+
+type redisSubscription struct {
+	owner *redisPubSub
+	ps    *redis.PubSub
+	done  chan struct{}
+	once  sync.Once
+	err   error
+}
+
+func (s *redisSubscription) run(ctx context.Context, topic string, handler Handler) {
+	defer close(s.done)
+	for m := range s.ps.Channel() {
+		var e envelope
+		if err := json.UnmarshalString(m.Payload, &e); err != nil {
+			log.Context(ctx).Warnf("pubsub: invalid %s message: %v", topic, err)
+			continue
+		}
+		msg := &Message{ID: e.ID, Topic: m.Channel, Payload: e.Payload, Metadata: e.Metadata}
+		if err := handler(ctx, msg); err != nil {
+			log.Context(ctx).Warnf("pubsub: handle %s message %s: %v", msg.Topic, msg.ID, err)
+		}
+	}
+}
+
+func (s *redisSubscription) Unsubscribe(ctx context.Context) error {
+	s.once.Do(func() {
+		s.owner.mu.Lock()
+		delete(s.owner.subs, s)
+		s.owner.mu.Unlock()
+		s.err = s.ps.Close()
+	})
+	if err := waitHandler(ctx, s, s.done); err != nil {
+		return err
+	}
+	return s.err
+}
@@ -0,0 +1,335 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/gopkg-dev/karma/cachex"
+	"github.com/gopkg-dev/karma/encoding/json"
+	"github.com/gopkg-dev/karma/log"
+	"github.com/gopkg-dev/karma/util"
+)
+
+const (
+	streamIDField       = "id"
+	streamPayloadField  = "payload"
+	streamMetadataField = "metadata"
+)
+
+type StreamConfig struct {
+	Group         string        // 消费组,同一消费组内每条消息只投递给一个消费者,默认 default
+	Consumer      string        // 消费者名称,默认随机生成
+	MaxLen        int64         // stream 最大长度(近似裁剪),0 表示不限制
+	BatchSize     int64         // 每次读取的消息数量,默认 10
+	Block         time.Duration // 阻塞读取超时时间,默认 2 秒
+	MinIdle       time.Duration // 消息超过该时长未确认时重新投递,默认 1 分钟
+	ClaimInterval time.Duration // 检查未确认消息的间隔,默认与 MinIdle 相同
+}
+
+// NewRedisStream 基于 Redis Streams 的发布订阅,支持消费组、确认及至少一次投递。
+// 同一实例上订阅同一主题的所有 handler 都会收到每条消息,全部成功后才确认;
+// 使用相同 Group 的不同实例(Consumer)之间竞争消费,每条消息只投递给其中一个实例
+func NewRedisStream(cfg cachex.RedisConfig, scfg StreamConfig) (PubSub, error) {
+	cli, err := cachex.NewRedisClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewRedisStreamWithClient(cli, scfg), nil
+}
+
+// NewRedisStreamWithClient Use redis client create streams pub/sub
+func NewRedisStreamWithClient(cli redis.UniversalClient, cfg StreamConfig) PubSub {
+	if cfg.Group == "" {
+		cfg.Group = "default"
+	}
+	if cfg.Consumer == "" {
+		cfg.Consumer = util.NewXID()
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 10
+	}
+	if cfg.Block <= 0 {
+		cfg.Block = 2 * time.Second
+	}
+	if cfg.MinIdle <= 0 {
+		cfg.MinIdle = time.Minute
+	}
+	if cfg.ClaimInterval <= 0 {
+		cfg.ClaimInterval = cfg.MinIdle
+	}
+	return &streamPubSub{cfg: cfg, cli: cli, topics: make(map[string]*streamTopic)}
+}
+
+type streamPubSub struct {
+	cfg    StreamConfig
+	cli    redis.UniversalClient
+	mu     sync.Mutex
+	topics map[string]*streamTopic
+}
+
+func (a *streamPubSub) Publish(ctx context.Context, msg *Message) error {
+	if msg.ID == "" {
+		msg.ID = util.NewXID()
+	}
+	values := map[string]interface{}{
+		streamIDField:      msg.ID,
+		streamPayloadField: msg.Payload,
+	}
+	if len(msg.Metadata) > 0 {
+		b, err := json.Marshal(msg.Metadata)
+		if err != nil {
+			return err
+		}
+		values[streamMetadataField] = b
+	}
+
+	return a.cli.XAdd(ctx, &redis.XAddArgs{
+		Stream: msg.Topic,
+		MaxLen: a.cfg.MaxLen,
+		Approx: a.cfg.MaxLen > 0,
+		Values: values,
+	}).Err()
+}
+
+func (a *streamPubSub) Subscribe(ctx context.Context, topic string, handler Handler) (Subscription, error) {
+	sub := &streamSubscription{ps: a, topic: topic, handler: handler}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	t := a.topics[topic]
+	if t == nil {
+		// 新建的消费组只接收之后发布的消息
+		err := a.cli.XGroupCreateMkStream(ctx, topic, a.cfg.Group, "$").Err()
+		if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		t = &streamTopic{
+			ps:     a,
+			topic:  topic,
+			subs:   make(map[*streamSubscription]struct{}),
+			cancel: cancel,
+			done:   make(chan struct{}),
+		}
+		a.topics[topic] = t
+		go t.run(ctx)
+	}
+	sub.t = t
+
+	t.mu.Lock()
+	t.subs[sub] = struct{}{}
+	t.mu.Unlock()
+	return sub, nil
+}
+
+func (a *streamPubSub) Close() error {
+	a.mu.Lock()
+	var (
+		subs   []*streamSubscription
+		topics []*streamTopic
+	)
+	for _, t := range a.topics {
+		topics = append(topics, t)
+		t.mu.Lock()
+		for sub := range t.subs {
+			subs = append(subs, sub)
+		}
+		t.mu.Unlock()
+	}
+	a.mu.Unlock()
+
+	for _, sub := range subs {
+		_ = sub.Unsubscribe(context.Background())
+	}
+	// 等待读取协程处理完当前消息后再关闭连接
+	for _, t := range topics {
+		<-t.done
+	}
+	return a.cli.Close()
+}
+
+// streamTopic 每个主题一个读取协程,将消息分发给该主题的所有订阅
+type streamTopic struct {
+	ps     *streamPubSub
+	topic  string
+	mu     sync.Mutex
+	subs   map[*streamSubscription]struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (t *streamTopic) run(ctx context.Context) {
+	defer close(t.done)
+
+	cfg := t.ps.cfg
+	ticker := time.NewTicker(cfg.ClaimInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.claim(ctx)
+			continue
+		default:
+		}
+
+		streams, err := t.ps.cli.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    cfg.Group,
+			Consumer: cfg.Consumer,
+			Streams:  []string{t.topic, ">"},
+			Count:    cfg.BatchSize,
+			Block:    cfg.Block,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+				continue
+			}
+			log.Context(ctx).Warnf("pubsub: read %s: %v", t.topic, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		for _, stream := range streams {
+			for _, m := range stream.Messages {
+				t.handle(ctx, m)
+			}
+		}
+	}
+}
+
+// claim 重新投递超过 MinIdle 未确认的消息
+func (t *streamTopic) claim(ctx context.Context) {
+	cfg := t.ps.cfg
+	start := "0-0"
+	for {
+		msgs, next, err := t.ps.cli.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   t.topic,
+			Group:    cfg.Group,
+			Consumer: cfg.Consumer,
+			MinIdle:  cfg.MinIdle,
+			Start:    start,
+			Count:    cfg.BatchSize,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Context(ctx).Warnf("pubsub: claim %s: %v", t.topic, err)
+			}
+			return
+		}
+		for _, m := range msgs {
+			t.handle(ctx, m)
+		}
+		if next == "0-0" || len(msgs) == 0 {
+			return
+		}
+		start = next
+	}
+}
+
+func (t *streamTopic) handle(ctx context.Context, m redis.XMessage) {
+	id, _ := m.Values[streamIDField].(string)
+	if id == "" {
+		id = m.ID
+	}
+	var payload []byte
+	if v, ok := m.Values[streamPayloadField].(string); ok {
+		payload = []byte(v)
+	}
+	var metadata map[string]string
+	if v, ok := m.Values[streamMetadataField].(string); ok {
+		if err := json.UnmarshalString(v, &metadata); err != nil {
+			log.Context(ctx).Warnf("pubsub: invalid %s message %s metadata: %v", t.topic, id, err)
+		}
+	}
+
+	t.mu.Lock()
+	subs := make([]*streamSubscription, 0, len(t.subs))
+	for sub := range t.subs {
+		subs = append(subs, sub)
+	}
+	t.mu.Unlock()
+
+	// 取消订阅只停止读取,已取出的消息仍需处理完成并确认
+	hctx := context.WithoutCancel(ctx)
+	handled, failed := 0, false
+	for _, sub := range subs {
+		if !t.acquire(sub) {
+			continue
+		}
+		msg := &Message{ID: id, Topic: t.topic, Payload: payload}
+		if metadata != nil {
+			msg.Metadata = make(map[string]string, len(metadata))
+			for k, v := range metadata {
+				msg.Metadata[k] = v
+			}
+		}
+		handled++
+		err := sub.handler(handlerContext(hctx, sub), msg)
+		sub.wg.Done()
+		if err != nil {
+			log.Context(ctx).Warnf("pubsub: handle %s message %s: %v", t.topic, id, err)
+			failed = true
+		}
+	}
+	if failed || handled == 0 {
+		// 不确认,超过 MinIdle 后重新投递给所有订阅
+		return
+	}
+	if err := t.ps.cli.XAck(hctx, t.topic, t.ps.cfg.Group, m.ID).Err(); err != nil {
+		log.Context(ctx).Warnf("pubsub: ack %s message %s: %v", t.topic, id, err)
+	}
+}
+
+// acquire 订阅仍然有效时登记一次 handler 调用,调用结束后需执行 sub.wg.Done
+func (t *streamTopic) acquire(sub *streamSubscription) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.subs[sub]; !ok {
+		return false
+	}
+	sub.wg.Add(1)
+	return true
+}
+
+type streamSubscription struct {
+	ps      *streamPubSub
+	t       *streamTopic
+	topic   string
+	handler Handler
+	once    sync.Once
+	wg      sync.WaitGroup // 正在执行的 handler
+}
+
+// Unsubscribe 取消订阅,之后分发的消息不再投递给该订阅,主题没有订阅时停止读取
+func (s *streamSubscription) Unsubscribe(ctx context.Context) error {
+	s.once.Do(func() {
+		s.ps.mu.Lock()
+		s.t.mu.Lock()
+		delete(s.t.subs, s)
+		empty := len(s.t.subs) == 0
+		s.t.mu.Unlock()
+		if empty {
+			delete(s.ps.topics, s.topic)
+			s.t.cancel()
+		}
+		s.ps.mu.Unlock()
+	})
+
+	// 移出 t.subs 后不会再登记新的调用
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	return waitHandler(ctx, s, done)
+}
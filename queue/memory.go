@@ -0,0 +1,234 @@
+package queue
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// NewMemoryBroker 创建内存任务存储,数据不持久化,适用于单实例及测试
+func NewMemoryBroker() Broker {
+	return &memoryBroker{
+		jobs:    make(map[string]*Job),
+		pending: make(map[string]struct{}),
+		active:  make(map[string]time.Time),
+		dead:    make(map[string]struct{}),
+		unique:  make(map[string]memoryUnique),
+	}
+}
+
+type memoryUnique struct {
+	id       string
+	expireAt time.Time // 零值表示直到任务完成
+}
+
+type memoryBroker struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	pending map[string]struct{}
+	active  map[string]time.Time // 租约到期时间
+	dead    map[string]struct{}
+	unique  map[string]memoryUnique
+}
+
+// clone 返回任务副本,避免调用方修改内部数据
+func clone(job *Job) *Job {
+	j := *job
+	return &j
+}
+
+func (a *memoryBroker) Enqueue(ctx context.Context, job *Job, uniqueTTL time.Duration) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if job.UniqueKey != "" {
+		now := time.Now()
+		if u, ok := a.unique[job.UniqueKey]; ok && (u.expireAt.IsZero() || now.Before(u.expireAt)) {
+			return ErrDuplicateJob
+		}
+		u := memoryUnique{id: job.ID}
+		if uniqueTTL > 0 {
+			u.expireAt = now.Add(uniqueTTL)
+		}
+		a.unique[job.UniqueKey] = u
+	}
+	a.jobs[job.ID] = clone(job)
+	a.pending[job.ID] = struct{}{}
+	return nil
+}
+
+func (a *memoryBroker) Dequeue(ctx context.Context, lease time.Duration) (*Job, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	for id, deadline := range a.active {
+		if now.After(deadline) {
+			job := a.jobs[id]
+			job.Attempts++
+			job.LastError = errLeaseExpired.Error()
+			delete(a.active, id)
+			a.pending[id] = struct{}{}
+		}
+	}
+
+	var next *Job
+	for id := range a.pending {
+		job := a.jobs[id]
+		if job.ProcessAt.After(now) {
+			continue
+		}
+		if next == nil || job.ProcessAt.Before(next.ProcessAt) {
+			next = job
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+	delete(a.pending, next.ID)
+	a.active[next.ID] = now.Add(lease)
+	job := clone(next)
+	job.lease = a.active[next.ID]
+	return job, nil
+}
+
+// leased 判断任务是否仍持有 Dequeue 分配的租约,调用方需持有锁
+func (a *memoryBroker) leased(job *Job) bool {
+	deadline, ok := a.active[job.ID]
+	return ok && deadline.Equal(job.lease)
+}
+
+func (a *memoryBroker) releaseUnique(job *Job) {
+	if u, ok := a.unique[job.UniqueKey]; ok && u.id == job.ID {
+		delete(a.unique, job.UniqueKey)
+	}
+}
+
+func (a *memoryBroker) Ack(ctx context.Context, job *Job) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.leased(job) {
+		return ErrLeaseLost
+	}
+	delete(a.active, job.ID)
+	delete(a.jobs, job.ID)
+	a.releaseUnique(job)
+	return nil
+}
+
+func (a *memoryBroker) Retry(ctx context.Context, job *Job, processAt time.Time) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.leased(job) {
+		return ErrLeaseLost
+	}
+	job.ProcessAt = processAt
+	job = clone(job)
+	delete(a.active, job.ID)
+	a.jobs[job.ID] = job
+	a.pending[job.ID] = struct{}{}
+	return nil
+}
+
+func (a *memoryBroker) Kill(ctx context.Context, job *Job) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.leased(job) {
+		return ErrLeaseLost
+	}
+	job.FailedAt = time.Now()
+	job = clone(job)
+	delete(a.active, job.ID)
+	a.jobs[job.ID] = job
+	a.dead[job.ID] = struct{}{}
+	a.releaseUnique(job)
+	return nil
+}
+
+// list 按 less 排序后分页,调用方需持有锁
+func (a *memoryBroker) list(ids map[string]struct{}, offset, limit int, less func(a, b *Job) bool) []*Job {
+	jobs := make([]*Job, 0, len(ids))
+	for id := range ids {
+		jobs = append(jobs, a.jobs[id])
+	}
+	sort.Slice(jobs, func(i, j int) bool { return less(jobs[i], jobs[j]) })
+
+	if offset >= len(jobs) {
+		return []*Job{}
+	}
+	jobs = jobs[offset:]
+	if limit > 0 && limit < len(jobs) {
+		jobs = jobs[:limit]
+	}
+	for i, job := range jobs {
+		jobs[i] = clone(job)
+	}
+	return jobs
+}
+
+func (a *memoryBroker) Pending(ctx context.Context, offset, limit int) ([]*Job, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.list(a.pending, offset, limit, func(a, b *Job) bool { return a.ProcessAt.Before(b.ProcessAt) }), nil
+}
+
+func (a *memoryBroker) Dead(ctx context.Context, offset, limit int) ([]*Job, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.list(a.dead, offset, limit, func(a, b *Job) bool { return a.FailedAt.After(b.FailedAt) }), nil
+}
+
+func (a *memoryBroker) RetryDead(ctx context.Context, id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.dead[id]; !ok {
+		return ErrJobNotFound
+	}
+	job := a.jobs[id]
+	job.Attempts = 0
+	job.FailedAt = time.Time{}
+	job.ProcessAt = time.Now()
+	delete(a.dead, id)
+	a.pending[id] = struct{}{}
+	return nil
+}
+
+func (a *memoryBroker) DeleteDead(ctx context.Context, id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.dead[id]; !ok {
+		return ErrJobNotFound
+	}
+	delete(a.dead, id)
+	delete(a.jobs, id)
+	return nil
+}
+
+func (a *memoryBroker) Stats(ctx context.Context) (Stats, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	stats := Stats{
+		Active: int64(len(a.active)),
+		Dead:   int64(len(a.dead)),
+	}
+	for id := range a.pending {
+		if a.jobs[id].ProcessAt.After(now) {
+			stats.Scheduled++
+		} else {
+			stats.Pending++
+		}
+	}
+	return stats, nil
+}
+
+func (a *memoryBroker) Close() error {
+	return nil
+}
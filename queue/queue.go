@@ -0,0 +1,129 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gopkg-dev/karma/util"
+)
+
+var (
+	// ErrDuplicateJob 唯一任务已存在
+	ErrDuplicateJob = errors.New("queue: duplicate job")
+	// ErrJobNotFound 任务不存在
+	ErrJobNotFound = errors.New("queue: job not found")
+	// ErrLeaseLost 任务租约已到期并被重新投递,本次 Ack/Retry/Kill 被忽略
+	ErrLeaseLost = errors.New("queue: job lease lost")
+
+	// errLeaseExpired 任务租约到期被重新投递,视为一次失败的执行
+	errLeaseExpired = errors.New("queue: job lease expired")
+)
+
+// Job 任务
+type Job struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"` // 任务类型,按类型分发到处理器
+	Payload   []byte    `json:"payload"`
+	MaxRetry  int       `json:"max_retry"` // 最大重试次数,-1 表示使用 Server 的默认值
+	Attempts  int       `json:"attempts"`  // 已执行次数
+	LastError string    `json:"last_error,omitempty"`
+	UniqueKey string    `json:"unique_key,omitempty"`
+	ProcessAt time.Time `json:"process_at"` // 计划执行时间
+	CreatedAt time.Time `json:"created_at"`
+	FailedAt  time.Time `json:"failed_at,omitempty"` // 进入死信队列的时间
+
+	lease time.Time // Dequeue 时分配的租约到期时间,用于识别过期的 Ack/Retry/Kill
+}
+
+// Stats 队列统计
+type Stats struct {
+	Pending   int64 `json:"pending"`   // 等待执行
+	Scheduled int64 `json:"scheduled"` // 延迟执行
+	Active    int64 `json:"active"`    // 正在执行
+	Dead      int64 `json:"dead"`      // 死信
+}
+
+// Broker 任务存储
+type Broker interface {
+	// Enqueue 保存任务,UniqueKey 非空时在 uniqueTTL 内或任务完成前不允许重复,uniqueTTL <= 0 表示直到任务完成
+	Enqueue(ctx context.Context, job *Job, uniqueTTL time.Duration) error
+	// Dequeue 取出一个到期的任务,lease 内未 Ack/Retry/Kill 的任务会重新投递并计为一次执行,没有任务时返回 nil
+	Dequeue(ctx context.Context, lease time.Duration) (*Job, error)
+	// Ack 任务执行成功,job 须为 Dequeue 返回的任务,租约已被重新投递时返回 ErrLeaseLost
+	Ack(ctx context.Context, job *Job) error
+	// Retry 任务在 processAt 重新执行,租约已被重新投递时返回 ErrLeaseLost
+	Retry(ctx context.Context, job *Job, processAt time.Time) error
+	// Kill 任务移入死信队列,租约已被重新投递时返回 ErrLeaseLost
+	Kill(ctx context.Context, job *Job) error
+
+	// Pending 按计划执行时间列出等待中(含延迟)的任务
+	Pending(ctx context.Context, offset, limit int) ([]*Job, error)
+	// Dead 按失败时间倒序列出死信任务
+	Dead(ctx context.Context, offset, limit int) ([]*Job, error)
+	// RetryDead 将死信任务重新加入队列
+	RetryDead(ctx context.Context, id string) error
+	// DeleteDead 删除死信任务
+	DeleteDead(ctx context.Context, id string) error
+	// Stats 队列统计
+	Stats(ctx context.Context) (Stats, error)
+	Close() error
+}
+
+type enqueueOptions struct {
+	processAt time.Time
+	maxRetry  int
+	uniqueKey string
+	uniqueTTL time.Duration
+}
+
+type EnqueueOption func(*enqueueOptions)
+
+// WithDelay 延迟 d 后执行
+func WithDelay(d time.Duration) EnqueueOption {
+	return func(o *enqueueOptions) { o.processAt = time.Now().Add(d) }
+}
+
+// WithProcessAt 在指定时间执行
+func WithProcessAt(t time.Time) EnqueueOption {
+	return func(o *enqueueOptions) { o.processAt = t }
+}
+
+// WithMaxRetry 设置最大重试次数,0 表示不重试
+func WithMaxRetry(n int) EnqueueOption {
+	return func(o *enqueueOptions) { o.maxRetry = n }
+}
+
+// WithUnique 设置唯一键,ttl 内或任务完成前相同唯一键的任务返回 ErrDuplicateJob,ttl <= 0 表示直到任务完成(Ack 或进入死信队列)
+func WithUnique(key string, ttl time.Duration) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.uniqueKey = key
+		o.uniqueTTL = ttl
+	}
+}
+
+// Enqueue 添加任务
+func Enqueue(ctx context.Context, broker Broker, typ string, payload []byte, opts ...EnqueueOption) (*Job, error) {
+	now := time.Now()
+	o := &enqueueOptions{
+		processAt: now,
+		maxRetry:  -1,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	job := &Job{
+		ID:        util.NewXID(),
+		Type:      typ,
+		Payload:   payload,
+		MaxRetry:  o.maxRetry,
+		ProcessAt: o.processAt,
+		CreatedAt: now,
+	}
+	job.UniqueKey = o.uniqueKey
+	if err := broker.Enqueue(ctx, job, o.uniqueTTL); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
@@ -0,0 +1,287 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRedisBroker(t *testing.T) Broker {
+	cli := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 1})
+	if err := cli.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("redis is not available: %v", err)
+	}
+	name := t.Name()
+	keys, _ := cli.Keys(context.Background(), "queue:{"+name+"}:*").Result()
+	if len(keys) > 0 {
+		cli.Del(context.Background(), keys...)
+	}
+	return NewRedisBrokerWithClient(cli, name)
+}
+
+func testBroker(t *testing.T, broker Broker) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	j1, err := Enqueue(ctx, broker, "email", []byte("a"))
+	assert.Nil(err)
+	j2, err := Enqueue(ctx, broker, "email", []byte("b"), WithDelay(time.Hour))
+	assert.Nil(err)
+	_, err = Enqueue(ctx, broker, "report", []byte("c"), WithUnique("report:1", time.Minute))
+	assert.Nil(err)
+	_, err = Enqueue(ctx, broker, "report", []byte("c"), WithUnique("report:1", time.Minute))
+	assert.ErrorIs(err, ErrDuplicateJob)
+
+	stats, err := broker.Stats(ctx)
+	assert.Nil(err)
+	assert.Equal(Stats{Pending: 2, Scheduled: 1}, stats)
+
+	pending, err := broker.Pending(ctx, 0, 10)
+	assert.Nil(err)
+	assert.Len(pending, 3)
+	assert.Equal(j2.ID, pending[2].ID)
+
+	// due jobs are dequeued in process order, delayed jobs are not
+	first, err := broker.Dequeue(ctx, time.Minute)
+	assert.Nil(err)
+	assert.Equal(j1.ID, first.ID)
+	assert.Equal([]byte("a"), first.Payload)
+	unique, err := broker.Dequeue(ctx, time.Minute)
+	assert.Nil(err)
+	assert.Equal("report", unique.Type)
+	job, err := broker.Dequeue(ctx, time.Minute)
+	assert.Nil(err)
+	assert.Nil(job)
+
+	// unique key is released once the job finishes
+	assert.Nil(broker.Ack(ctx, unique))
+	_, err = Enqueue(ctx, broker, "report", []byte("c"), WithUnique("report:1", time.Minute))
+	assert.Nil(err)
+
+	// expired lease is redelivered
+	job, err = broker.Dequeue(ctx, time.Millisecond)
+	assert.Nil(err)
+	assert.NotNil(job)
+	time.Sleep(5 * time.Millisecond)
+	job, err = broker.Dequeue(ctx, time.Minute)
+	assert.Nil(err)
+	assert.NotNil(job)
+	assert.Equal(1, job.Attempts)
+	assert.Equal(errLeaseExpired.Error(), job.LastError)
+
+	job.LastError = "boom"
+	assert.Nil(broker.Kill(ctx, job))
+	dead, err := broker.Dead(ctx, 0, 10)
+	assert.Nil(err)
+	assert.Len(dead, 1)
+	assert.Equal("boom", dead[0].LastError)
+	assert.False(dead[0].FailedAt.IsZero())
+
+	assert.Nil(broker.RetryDead(ctx, job.ID))
+	assert.ErrorIs(broker.RetryDead(ctx, job.ID), ErrJobNotFound)
+	job, err = broker.Dequeue(ctx, time.Minute)
+	assert.Nil(err)
+	assert.Equal(0, job.Attempts)
+
+	assert.Nil(broker.Kill(ctx, job))
+	assert.Nil(broker.DeleteDead(ctx, job.ID))
+	assert.ErrorIs(broker.DeleteDead(ctx, job.ID), ErrJobNotFound)
+
+	j3, err := broker.Dequeue(ctx, time.Minute)
+	assert.Nil(err)
+	assert.Nil(j3)
+	assert.ErrorIs(broker.Retry(ctx, j1, time.Now()), ErrLeaseLost)
+	assert.Nil(broker.Retry(ctx, first, time.Now()))
+	j3, err = broker.Dequeue(ctx, time.Minute)
+	assert.Nil(err)
+	assert.Equal(j1.ID, j3.ID)
+
+	stats, err = broker.Stats(ctx)
+	assert.Nil(err)
+	assert.Equal(Stats{Scheduled: 1, Active: 1}, stats)
+
+	// zero ttl keeps the unique key until the job finishes
+	j4, err := Enqueue(ctx, broker, "sync", nil, WithUnique("sync:1", 0))
+	assert.Nil(err)
+	_, err = Enqueue(ctx, broker, "sync", nil, WithUnique("sync:1", 0))
+	assert.ErrorIs(err, ErrDuplicateJob)
+	j4, err = broker.Dequeue(ctx, time.Minute)
+	assert.Nil(err)
+	assert.Equal("sync", j4.Type)
+	assert.Nil(broker.Ack(ctx, j4))
+	_, err = Enqueue(ctx, broker, "sync", nil, WithUnique("sync:1", 0))
+	assert.Nil(err)
+	assert.Nil(broker.Close())
+}
+
+func TestBroker(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testBroker(t, NewMemoryBroker())
+	})
+	t.Run("redis", func(t *testing.T) {
+		testBroker(t, newTestRedisBroker(t))
+	})
+}
+
+func testBrokerStaleLease(t *testing.T, broker Broker) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	_, err := Enqueue(ctx, broker, "email", nil, WithUnique("email:1", 0))
+	assert.Nil(err)
+	stale, err := broker.Dequeue(ctx, time.Millisecond)
+	assert.Nil(err)
+	time.Sleep(5 * time.Millisecond)
+
+	// the expired lease is reclaimed, the old worker's ack is ignored
+	job, err := broker.Dequeue(ctx, time.Minute)
+	assert.Nil(err)
+	assert.Equal(stale.ID, job.ID)
+	assert.ErrorIs(broker.Ack(ctx, stale), ErrLeaseLost)
+	assert.ErrorIs(broker.Retry(ctx, stale, time.Now()), ErrLeaseLost)
+	assert.ErrorIs(broker.Kill(ctx, stale), ErrLeaseLost)
+
+	stats, err := broker.Stats(ctx)
+	assert.Nil(err)
+	assert.Equal(Stats{Active: 1}, stats)
+	_, err = Enqueue(ctx, broker, "email", nil, WithUnique("email:1", 0))
+	assert.ErrorIs(err, ErrDuplicateJob)
+
+	// an ack after the lease expired but before it is reclaimed leaves the job queued
+	assert.Nil(broker.Retry(ctx, job, time.Now()))
+	stale, err = broker.Dequeue(ctx, time.Millisecond)
+	assert.Nil(err)
+	time.Sleep(5 * time.Millisecond)
+	assert.Nil(broker.Ack(ctx, stale))
+	stats, err = broker.Stats(ctx)
+	assert.Nil(err)
+	assert.Equal(Stats{}, stats)
+	assert.Nil(broker.Close())
+}
+
+func TestBrokerStaleLease(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testBrokerStaleLease(t, NewMemoryBroker())
+	})
+	t.Run("redis", func(t *testing.T) {
+		testBrokerStaleLease(t, newTestRedisBroker(t))
+	})
+}
+
+func TestRedisBrokerEnqueueFailure(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	broker := newTestRedisBroker(t).(*redisBroker)
+
+	// the job hash has the wrong type, so the write fails and the unique key must not be taken
+	assert.Nil(broker.cli.Set(ctx, broker.jobs, "x", 0).Err())
+	_, err := Enqueue(ctx, broker, "sync", nil, WithUnique("sync:1", 0))
+	assert.NotNil(err)
+	assert.NotErrorIs(err, ErrDuplicateJob)
+	assert.Equal(int64(0), broker.cli.Exists(ctx, broker.uniqueKey("sync:1")).Val())
+
+	assert.Nil(broker.cli.Del(ctx, broker.jobs).Err())
+	_, err = Enqueue(ctx, broker, "sync", nil, WithUnique("sync:1", 0))
+	assert.Nil(err)
+	assert.Nil(broker.Close())
+}
+
+func testServer(t *testing.T, broker Broker) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	var (
+		mu   sync.Mutex
+		dead []*Job
+		sent int32
+		fail int32
+	)
+	srv := NewServer(broker,
+		WithConcurrency(4),
+		WithPollInterval(10*time.Millisecond),
+		WithBackoff(10*time.Millisecond, 20*time.Millisecond),
+		WithDefaultMaxRetry(2),
+		WithDeadLetter(func(ctx context.Context, job *Job) {
+			mu.Lock()
+			defer mu.Unlock()
+			dead = append(dead, job)
+		}),
+	)
+	srv.Handle("email", func(ctx context.Context, job *Job) error {
+		// first attempt fails, retry succeeds
+		if atomic.AddInt32(&fail, 1) == 1 {
+			return errors.New("smtp unavailable")
+		}
+		atomic.AddInt32(&sent, 1)
+		return nil
+	})
+	srv.Handle("report", func(ctx context.Context, job *Job) error {
+		return errors.New("always fails")
+	})
+	srv.Handle("panic", func(ctx context.Context, job *Job) error {
+		panic("boom")
+	})
+	var crashed int32
+	srv.Handle("crash", func(ctx context.Context, job *Job) error {
+		atomic.AddInt32(&crashed, 1)
+		return nil
+	})
+
+	// a worker took the job and crashed, the reclaimed lease counts as an attempt
+	_, err := Enqueue(ctx, broker, "crash", nil, WithMaxRetry(0))
+	assert.Nil(err)
+	job, err := broker.Dequeue(ctx, time.Millisecond)
+	assert.Nil(err)
+	assert.Equal("crash", job.Type)
+	time.Sleep(5 * time.Millisecond)
+
+	_, err = Enqueue(ctx, broker, "email", []byte("a"))
+	assert.Nil(err)
+	_, err = Enqueue(ctx, broker, "report", []byte("b"))
+	assert.Nil(err)
+	_, err = Enqueue(ctx, broker, "panic", nil, WithMaxRetry(0))
+	assert.Nil(err)
+	_, err = Enqueue(ctx, broker, "unknown", nil)
+	assert.Nil(err)
+
+	go func() { _ = srv.Start(ctx) }()
+
+	assert.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return atomic.LoadInt32(&sent) == 1 && len(dead) == 4
+	}, 3*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	attempts := make(map[string]int)
+	for _, job := range dead {
+		attempts[job.Type] = job.Attempts
+	}
+	mu.Unlock()
+	assert.Equal(map[string]int{"report": 3, "panic": 1, "unknown": 1, "crash": 1}, attempts)
+	assert.Equal(int32(0), atomic.LoadInt32(&crashed))
+
+	assert.Nil(srv.Stop(ctx))
+	// starting again after stop returns immediately
+	assert.Nil(srv.Start(ctx))
+
+	stats, err := broker.Stats(ctx)
+	assert.Nil(err)
+	assert.Equal(Stats{Dead: 4}, stats)
+	assert.Nil(broker.Close())
+}
+
+func TestServer(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testServer(t, NewMemoryBroker())
+	})
+	t.Run("redis", func(t *testing.T) {
+		testServer(t, newTestRedisBroker(t))
+	})
+}
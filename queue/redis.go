@@ -0,0 +1,330 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/gopkg-dev/karma/cachex"
+	"github.com/gopkg-dev/karma/encoding/json"
+)
+
+// NewRedisBroker 创建 Redis 任务存储,name 为队列名称
+func NewRedisBroker(cfg cachex.RedisConfig, name string) (Broker, error) {
+	cli, err := cachex.NewRedisClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewRedisBrokerWithClient(cli, name), nil
+}
+
+// NewRedisBrokerWithClient Use redis client create broker
+func NewRedisBrokerWithClient(cli redis.UniversalClient, name string) Broker {
+	// hash tag 保证集群模式下所有 key 位于同一 slot
+	prefix := fmt.Sprintf("queue:{%s}:", name)
+	return &redisBroker{
+		cli:     cli,
+		prefix:  prefix,
+		jobs:    prefix + "jobs",
+		pending: prefix + "pending",
+		active:  prefix + "active",
+		dead:    prefix + "dead",
+	}
+}
+
+type redisBroker struct {
+	cli     redis.UniversalClient
+	prefix  string
+	jobs    string // hash: id -> job
+	pending string // zset: 计划执行时间
+	active  string // zset: 租约到期时间
+	dead    string // zset: 失败时间
+}
+
+func (a *redisBroker) uniqueKey(key string) string {
+	return a.prefix + "unique:" + key
+}
+
+// enqueueScript 唯一键不存在时保存任务并占用唯一键,写入失败时不会留下唯一键
+var enqueueScript = redis.NewScript(`
+if #KEYS == 3 and redis.call('EXISTS', KEYS[3]) == 1 then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('ZADD', KEYS[2], ARGV[3], ARGV[1])
+if #KEYS == 3 then
+	if tonumber(ARGV[4]) > 0 then
+		redis.call('SET', KEYS[3], ARGV[1], 'PX', ARGV[4])
+	else
+		redis.call('SET', KEYS[3], ARGV[1])
+	end
+end
+return 1
+`)
+
+func (a *redisBroker) Enqueue(ctx context.Context, job *Job, uniqueTTL time.Duration) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{a.jobs, a.pending}
+	if job.UniqueKey != "" {
+		keys = append(keys, a.uniqueKey(job.UniqueKey))
+	}
+	var ttl int64 // 0 表示不过期,直到任务完成
+	if uniqueTTL > 0 {
+		ttl = max(uniqueTTL.Milliseconds(), 1)
+	}
+	ok, err := enqueueScript.Run(ctx, a.cli, keys, job.ID, b, job.ProcessAt.UnixMilli(), ttl).Bool()
+	if err != nil {
+		return err
+	} else if !ok {
+		return ErrDuplicateJob
+	}
+	return nil
+}
+
+// dequeueScript 将租约到期的任务计为一次执行后放回队列,并取出一个到期的任务
+var dequeueScript = redis.NewScript(`
+local expired = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1])
+for _, id in ipairs(expired) do
+	redis.call('ZREM', KEYS[2], id)
+	local data = redis.call('HGET', KEYS[3], id)
+	if data then
+		local job = cjson.decode(data)
+		job.attempts = (job.attempts or 0) + 1
+		job.last_error = ARGV[3]
+		redis.call('HSET', KEYS[3], id, cjson.encode(job))
+		redis.call('ZADD', KEYS[1], ARGV[1], id)
+	end
+end
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 1)
+if #ids == 0 then
+	return false
+end
+redis.call('ZREM', KEYS[1], ids[1])
+redis.call('ZADD', KEYS[2], ARGV[2], ids[1])
+return redis.call('HGET', KEYS[3], ids[1])
+`)
+
+func (a *redisBroker) Dequeue(ctx context.Context, lease time.Duration) (*Job, error) {
+	now := time.Now()
+	deadline := now.Add(lease).UnixMilli()
+	s, err := dequeueScript.Run(ctx, a.cli, []string{a.pending, a.active, a.jobs},
+		now.UnixMilli(), deadline, errLeaseExpired.Error()).Text()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var job Job
+	if err := json.UnmarshalString(s, &job); err != nil {
+		return nil, err
+	}
+	job.lease = time.UnixMilli(deadline)
+	return &job, nil
+}
+
+// releaseUniqueScript 唯一键仍属于该任务时删除
+var releaseUniqueScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func (a *redisBroker) releaseUnique(ctx context.Context, job *Job) error {
+	if job.UniqueKey == "" {
+		return nil
+	}
+	return releaseUniqueScript.Run(ctx, a.cli, []string{a.uniqueKey(job.UniqueKey)}, job.ID).Err()
+}
+
+// leaseCheck 任务不在 active 中或租约已被重新分配时返回 0,KEYS[1] 为 active,ARGV[1] 为任务 id,ARGV[2] 为租约到期时间
+const leaseCheck = `
+local lease = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not lease or tonumber(lease) ~= tonumber(ARGV[2]) then
+	return 0
+end
+`
+
+// ackScript 租约有效时删除任务
+var ackScript = redis.NewScript(leaseCheck + `
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+return 1
+`)
+
+// moveScript 租约有效时更新任务并从 active 移入 KEYS[3](pending 或 dead)
+var moveScript = redis.NewScript(leaseCheck + `
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[3])
+redis.call('ZADD', KEYS[3], ARGV[4], ARGV[1])
+return 1
+`)
+
+func (a *redisBroker) Ack(ctx context.Context, job *Job) error {
+	ok, err := ackScript.Run(ctx, a.cli, []string{a.active, a.jobs}, job.ID, job.lease.UnixMilli()).Bool()
+	if err != nil {
+		return err
+	} else if !ok {
+		return ErrLeaseLost
+	}
+	return a.releaseUnique(ctx, job)
+}
+
+// move 租约有效时保存任务并将其从 active 移入 to,score 为 to 中的排序值
+func (a *redisBroker) move(ctx context.Context, job *Job, to string, score int64) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	ok, err := moveScript.Run(ctx, a.cli, []string{a.active, a.jobs, to}, job.ID, job.lease.UnixMilli(), b, score).Bool()
+	if err != nil {
+		return err
+	} else if !ok {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (a *redisBroker) Retry(ctx context.Context, job *Job, processAt time.Time) error {
+	job.ProcessAt = processAt
+	return a.move(ctx, job, a.pending, processAt.UnixMilli())
+}
+
+func (a *redisBroker) Kill(ctx context.Context, job *Job) error {
+	job.FailedAt = time.Now()
+	if err := a.move(ctx, job, a.dead, job.FailedAt.UnixMilli()); err != nil {
+		return err
+	}
+	return a.releaseUnique(ctx, job)
+}
+
+// list 按 id 获取任务
+func (a *redisBroker) list(ctx context.Context, ids []string) ([]*Job, error) {
+	jobs := make([]*Job, 0, len(ids))
+	if len(ids) == 0 {
+		return jobs, nil
+	}
+	vals, err := a.cli.HMGet(ctx, a.jobs, ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, val := range vals {
+		s, ok := val.(string)
+		if !ok {
+			continue
+		}
+		var job Job
+		if err := json.UnmarshalString(s, &job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, &job)
+	}
+	return jobs, nil
+}
+
+// stop 返回分页的结束位置,limit <= 0 时返回 -1(到末尾)
+func stop(offset, limit int) int64 {
+	if limit <= 0 {
+		return -1
+	}
+	return int64(offset + limit - 1)
+}
+
+func (a *redisBroker) Pending(ctx context.Context, offset, limit int) ([]*Job, error) {
+	ids, err := a.cli.ZRange(ctx, a.pending, int64(offset), stop(offset, limit)).Result()
+	if err != nil {
+		return nil, err
+	}
+	return a.list(ctx, ids)
+}
+
+func (a *redisBroker) Dead(ctx context.Context, offset, limit int) ([]*Job, error) {
+	ids, err := a.cli.ZRevRange(ctx, a.dead, int64(offset), stop(offset, limit)).Result()
+	if err != nil {
+		return nil, err
+	}
+	return a.list(ctx, ids)
+}
+
+func (a *redisBroker) deadJob(ctx context.Context, id string) (*Job, error) {
+	if err := a.cli.ZScore(ctx, a.dead, id).Err(); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+	jobs, err := a.list(ctx, []string{id})
+	if err != nil {
+		return nil, err
+	} else if len(jobs) == 0 {
+		return nil, ErrJobNotFound
+	}
+	return jobs[0], nil
+}
+
+func (a *redisBroker) RetryDead(ctx context.Context, id string) error {
+	job, err := a.deadJob(ctx, id)
+	if err != nil {
+		return err
+	}
+	job.Attempts = 0
+	job.FailedAt = time.Time{}
+	job.ProcessAt = time.Now()
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	_, err = a.cli.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, a.jobs, job.ID, b)
+		pipe.ZRem(ctx, a.dead, job.ID)
+		pipe.ZAdd(ctx, a.pending, redis.Z{Score: float64(job.ProcessAt.UnixMilli()), Member: job.ID})
+		return nil
+	})
+	return err
+}
+
+func (a *redisBroker) DeleteDead(ctx context.Context, id string) error {
+	n, err := a.cli.ZRem(ctx, a.dead, id).Result()
+	if err != nil {
+		return err
+	} else if n == 0 {
+		return ErrJobNotFound
+	}
+	return a.cli.HDel(ctx, a.jobs, id).Err()
+}
+
+func (a *redisBroker) Stats(ctx context.Context) (Stats, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	var (
+		pending, scheduled, active, dead *redis.IntCmd
+	)
+	_, err := a.cli.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pending = pipe.ZCount(ctx, a.pending, "-inf", now)
+		scheduled = pipe.ZCount(ctx, a.pending, "("+now, "+inf")
+		active = pipe.ZCard(ctx, a.active)
+		dead = pipe.ZCard(ctx, a.dead)
+		return nil
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+	return Stats{
+		Pending:   pending.Val(),
+		Scheduled: scheduled.Val(),
+		Active:    active.Val(),
+		Dead:      dead.Val(),
+	}, nil
+}
+
+func (a *redisBroker) Close() error {
+	return a.cli.Close()
+}
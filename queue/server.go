@@ -0,0 +1,229 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gopkg-dev/karma/log"
+	"github.com/gopkg-dev/karma/util"
+)
+
+// ErrSkipRetry 由 Handler 返回(可包装)时任务直接进入死信队列
+var ErrSkipRetry = errors.New("queue: skip retry")
+
+// leaseMargin 任务租约在执行超时之外预留的时长,覆盖出队到开始执行以及 Ack/Retry 的耗时,
+// 避免仍在执行的任务因租约到期被其他 worker 重复执行
+const leaseMargin = time.Minute
+
+// Handler 处理任务,返回错误时按退避策略重试
+type Handler func(ctx context.Context, job *Job) error
+
+type serverOptions struct {
+	concurrency  int
+	pollInterval time.Duration
+	maxRetry     int
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	timeout      time.Duration
+	deadLetter   func(ctx context.Context, job *Job)
+}
+
+type ServerOption func(*serverOptions)
+
+// WithConcurrency 设置并发执行的任务数量
+func WithConcurrency(n int) ServerOption {
+	return func(o *serverOptions) { o.concurrency = n }
+}
+
+// WithPollInterval 设置队列为空时的轮询间隔
+func WithPollInterval(d time.Duration) ServerOption {
+	return func(o *serverOptions) { o.pollInterval = d }
+}
+
+// WithDefaultMaxRetry 设置任务未指定时的最大重试次数
+func WithDefaultMaxRetry(n int) ServerOption {
+	return func(o *serverOptions) { o.maxRetry = n }
+}
+
+// WithBackoff 设置重试退避的最小/最大间隔,按指数增长
+func WithBackoff(min, max time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.minBackoff = min
+		o.maxBackoff = max
+	}
+}
+
+// WithTimeout 设置单个任务的执行超时时间,任务租约时长为超时时间再加 1 分钟
+func WithTimeout(d time.Duration) ServerOption {
+	return func(o *serverOptions) { o.timeout = d }
+}
+
+// WithDeadLetter 设置任务进入死信队列时的回调
+func WithDeadLetter(fn func(ctx context.Context, job *Job)) ServerOption {
+	return func(o *serverOptions) { o.deadLetter = fn }
+}
+
+// Server 任务执行器,实现 transport.Server
+type Server struct {
+	broker   Broker
+	opts     *serverOptions
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	running  atomic.Bool
+	stopOnce sync.Once
+	doneOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewServer 创建任务执行器
+func NewServer(broker Broker, opts ...ServerOption) *Server {
+	o := &serverOptions{
+		concurrency:  10,
+		pollInterval: time.Second,
+		maxRetry:     3,
+		minBackoff:   time.Second,
+		maxBackoff:   10 * time.Minute,
+		timeout:      30 * time.Minute,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Server{
+		broker:   broker,
+		opts:     o,
+		handlers: make(map[string]Handler),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Handle 注册任务类型的处理器
+func (s *Server) Handle(typ string, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[typ] = handler
+}
+
+// Start 启动 worker,直到 ctx 取消或调用 Stop
+func (s *Server) Start(ctx context.Context) error {
+	s.running.Store(true)
+	defer s.doneOnce.Do(func() { close(s.done) })
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.opts.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(ctx)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// Stop 停止获取新任务并等待执行中的任务完成
+func (s *Server) Stop(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	if !s.running.Load() {
+		return nil
+	}
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stop:
+			return
+		default:
+		}
+
+		job, err := s.broker.Dequeue(ctx, s.opts.timeout+leaseMargin)
+		if err != nil && ctx.Err() == nil {
+			log.Context(ctx).Errorf("queue: dequeue: %v", err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.stop:
+				return
+			case <-time.After(s.opts.pollInterval):
+			}
+			continue
+		}
+		s.process(context.WithoutCancel(ctx), job)
+	}
+}
+
+func (s *Server) process(ctx context.Context, job *Job) {
+	maxRetry := job.MaxRetry
+	if maxRetry < 0 {
+		maxRetry = s.opts.maxRetry
+	}
+	// 执行中崩溃的任务在租约到期后重新投递,已计入执行次数
+	if job.Attempts > maxRetry {
+		s.kill(ctx, job, errLeaseExpired)
+		return
+	}
+
+	job.Attempts++
+	err := s.run(ctx, job)
+	if err == nil {
+		if err := s.broker.Ack(ctx, job); err != nil {
+			log.Context(ctx).Errorf("queue: ack job %s: %v", job.ID, err)
+		}
+		return
+	}
+
+	job.LastError = err.Error()
+	if errors.Is(err, ErrSkipRetry) || job.Attempts > maxRetry {
+		s.kill(ctx, job, err)
+		return
+	}
+
+	if err := s.broker.Retry(ctx, job, time.Now().Add(util.Backoff(s.opts.minBackoff, s.opts.maxBackoff, job.Attempts))); err != nil {
+		log.Context(ctx).Errorf("queue: retry job %s: %v", job.ID, err)
+	}
+}
+
+func (s *Server) kill(ctx context.Context, job *Job, err error) {
+	log.Context(ctx).Warnf("queue: job %s(%s) dead after %d attempts: %v", job.ID, job.Type, job.Attempts, err)
+	if err := s.broker.Kill(ctx, job); err != nil {
+		log.Context(ctx).Errorf("queue: kill job %s: %v", job.ID, err)
+	}
+	if s.opts.deadLetter != nil {
+		s.opts.deadLetter(ctx, job)
+	}
+}
+
+func (s *Server) run(ctx context.Context, job *Job) (err error) {
+	s.mu.RLock()
+	handler, ok := s.handlers[job.Type]
+	s.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: no handler for job type %q", ErrSkipRetry, job.Type)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("queue: panic: %v", r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, s.opts.timeout)
+	defer cancel()
+	return handler(ctx, job)
+}
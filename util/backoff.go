@@ -0,0 +1,15 @@
+package util
+
+import "time"
+
+// Backoff 计算第 attempts 次失败后的指数退避间隔,从 min 开始每次翻倍,不超过 max
+func Backoff(min, max time.Duration, attempts int) time.Duration {
+	d := min
+	for i := 1; i < attempts && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
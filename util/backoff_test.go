@@ -0,0 +1,15 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, Backoff(time.Second, time.Minute, 0))
+	assert.Equal(t, time.Second, Backoff(time.Second, time.Minute, 1))
+	assert.Equal(t, 4*time.Second, Backoff(time.Second, time.Minute, 3))
+	assert.Equal(t, time.Minute, Backoff(time.Second, time.Minute, 100))
+}
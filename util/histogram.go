@@ -0,0 +1,52 @@
+package util
+
+import (
+	"sort"
+	"time"
+)
+
+// LatencyBucket 直方图分桶,Count 为耗时小于等于 Le 的累计次数
+type LatencyBucket struct {
+	Le    time.Duration `json:"le"`
+	Count int64         `json:"count"`
+}
+
+// Histogram 耗时直方图,非并发安全,由调用方加锁
+type Histogram struct {
+	Count   int64           `json:"count"` // 记录次数
+	Sum     time.Duration   `json:"sum"`   // 总耗时
+	Buckets []LatencyBucket `json:"buckets"`
+}
+
+// SortBuckets 返回升序排列的分桶副本,用于 NewHistogram
+func SortBuckets(buckets []time.Duration) []time.Duration {
+	buckets = append([]time.Duration(nil), buckets...)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+	return buckets
+}
+
+// NewHistogram 按升序的分桶创建直方图
+func NewHistogram(buckets []time.Duration) Histogram {
+	h := Histogram{Buckets: make([]LatencyBucket, len(buckets))}
+	for i, le := range buckets {
+		h.Buckets[i].Le = le
+	}
+	return h
+}
+
+// Observe 记录一次耗时
+func (h *Histogram) Observe(elapsed time.Duration) {
+	h.Count++
+	h.Sum += elapsed
+	for i := range h.Buckets {
+		if elapsed <= h.Buckets[i].Le {
+			h.Buckets[i].Count++
+		}
+	}
+}
+
+// Clone 返回不共享分桶的副本
+func (h Histogram) Clone() Histogram {
+	h.Buckets = append([]LatencyBucket(nil), h.Buckets...)
+	return h
+}
@@ -0,0 +1,25 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogram(t *testing.T) {
+	buckets := SortBuckets([]time.Duration{time.Second, time.Millisecond})
+	assert.Equal(t, []time.Duration{time.Millisecond, time.Second}, buckets)
+
+	h := NewHistogram(buckets)
+	h.Observe(time.Millisecond)
+	h.Observe(10 * time.Millisecond)
+	h.Observe(time.Minute)
+	assert.Equal(t, int64(3), h.Count)
+	assert.Equal(t, time.Minute+11*time.Millisecond, h.Sum)
+	assert.Equal(t, []LatencyBucket{{Le: time.Millisecond, Count: 1}, {Le: time.Second, Count: 2}}, h.Buckets)
+
+	c := h.Clone()
+	h.Observe(0)
+	assert.Equal(t, int64(1), c.Buckets[0].Count)
+}